@@ -0,0 +1,260 @@
+// Command nanodbd runs a nanodb.DB as a standalone key-value server speaking the nanodbwire protocol.
+//
+//	nanodbd -listen tcp://127.0.0.1:7070 -data nanodb.tar -timeout 1h
+//
+// The data file is an archive written by DB.ExportArchive, saved every -save-interval and on shutdown.
+//
+// Under systemd socket activation the first passed socket is served instead of -listen.
+// SIGHUP rereads the -config file, a JSON object overriding the timeout, max_conns, idle_timeout,
+// save_interval and stats_interval flags, and applies it without a restart. SIGINT and SIGTERM
+// stop accepting, let connections finish their requests for up to -drain-timeout, save and exit.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+	"github.com/kittenbark/nanodb/nanodbwire"
+)
+
+type config struct {
+	listen        string
+	data          string
+	file          string
+	pid           string
+	timeout       time.Duration
+	saveInterval  time.Duration
+	statsInterval time.Duration
+	maxConns      int
+	idleTimeout   time.Duration
+	drainTimeout  time.Duration
+}
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.listen, "listen", "tcp://127.0.0.1:7070", "address to serve on, tcp://host:port or unix:///path")
+	flag.StringVar(&cfg.data, "data", "", "archive file the data is loaded from and saved to, empty keeps it in memory only")
+	flag.StringVar(&cfg.file, "config", "", "JSON file overriding the runtime flags, reread on SIGHUP")
+	flag.StringVar(&cfg.pid, "pid", "", "file to write the process id to")
+	flag.DurationVar(&cfg.timeout, "timeout", 0, "lifetime of entries, 0 keeps them forever")
+	flag.DurationVar(&cfg.saveInterval, "save-interval", time.Minute, "how often the data file is saved")
+	flag.DurationVar(&cfg.statsInterval, "stats-interval", time.Minute, "how often stats are logged, 0 disables them")
+	flag.IntVar(&cfg.maxConns, "max-conns", 1024, "maximum open connections, 0 means no limit")
+	flag.DurationVar(&cfg.idleTimeout, "idle-timeout", 5*time.Minute, "close connections idle for this long, 0 disables it")
+	flag.DurationVar(&cfg.drainTimeout, "drain-timeout", 10*time.Second, "how long shutdown waits for background work")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	if err := run(ctx, cfg, reloads); err != nil {
+		slog.Error("nanodbd", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config, reloads <-chan os.Signal) error {
+	cfg, err := cfg.overridden()
+	if err != nil {
+		return err
+	}
+	if cfg.pid != "" {
+		if err := os.WriteFile(cfg.pid, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			return err
+		}
+		defer os.Remove(cfg.pid)
+	}
+
+	db := nanodb.New[[]byte]().Timeout(cfg.timeout)
+	if err := load(db, cfg.data); err != nil {
+		return fmt.Errorf("load %s: %w", cfg.data, err)
+	}
+
+	listener, err := activated()
+	if listener == nil && err == nil {
+		listener, err = listen(cfg.listen)
+	}
+	if err != nil {
+		return err
+	}
+	server := nanodbwire.NewServer(db).MaxConns(cfg.maxConns).IdleTimeout(cfg.idleTimeout)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+	slog.Info("nanodbd serving", "listen", listener.Addr(), "entries", db.Len())
+
+	saves, stats := ticker(cfg.saveInterval), ticker(cfg.statsInterval)
+	defer saves.Stop()
+	defer stats.Stop()
+	for {
+		select {
+		case <-reloads:
+			reloaded, err := cfg.overridden()
+			if err != nil {
+				slog.Error("nanodbd reload", "err", err)
+				continue
+			}
+			// Timeout restarts every lifetime, so it is only applied when it changed.
+			if reloaded.timeout != cfg.timeout {
+				db.Timeout(reloaded.timeout)
+			}
+			server.MaxConns(reloaded.maxConns).IdleTimeout(reloaded.idleTimeout)
+			reset(saves, reloaded.saveInterval)
+			reset(stats, reloaded.statsInterval)
+			cfg = reloaded
+			slog.Info("nanodbd reloaded", "config", cfg.file)
+		case <-saves.C:
+			if err := save(db, cfg.data); err != nil {
+				slog.Error("nanodbd save", "err", err)
+			}
+		case <-stats.C:
+			slog.Info("nanodbd stats", "stats", db.Stats())
+		case err := <-served:
+			return errors.Join(err, shutdown(db, server, cfg))
+		case <-ctx.Done():
+			slog.Info("nanodbd shutting down")
+			return shutdown(db, server, cfg)
+		}
+	}
+}
+
+func shutdown(db *nanodb.DB[[]byte], server *nanodbwire.Server, cfg config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.drainTimeout)
+	defer cancel()
+
+	// Closing a unix listener unlinks the socket only when net.Listen created it,
+	// a socket passed by systemd stays for the next activation.
+	return errors.Join(server.Shutdown(ctx), db.Shutdown(ctx), save(db, cfg.data))
+}
+
+func listen(address string) (net.Listener, error) {
+	network, address, ok := strings.Cut(address, "://")
+	if !ok || (network != "tcp" && network != "unix") {
+		return nil, fmt.Errorf("listen address %q is not tcp://host:port or unix:///path", address)
+	}
+	return net.Listen(network, address)
+}
+
+func load(db *nanodb.DB[[]byte], filename string) error {
+	if filename == "" {
+		return nil
+	}
+	archive, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	return db.ImportArchive(archive)
+}
+
+// save writes the archive next to filename first, so a crash mid-save keeps the previous one intact.
+func save(db *nanodb.DB[[]byte], filename string) (err error) {
+	if filename == "" {
+		return nil
+	}
+	temp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if err := db.ExportArchive(temp); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filename)
+}
+
+// ticker never fires for a zero interval.
+func ticker(interval time.Duration) *time.Ticker {
+	ticker := time.NewTicker(time.Hour)
+	reset(ticker, interval)
+	return ticker
+}
+
+func reset(ticker *time.Ticker, interval time.Duration) {
+	if interval <= 0 {
+		ticker.Stop()
+		return
+	}
+	ticker.Reset(interval)
+}
+
+// overridden returns cfg with the values of the config file, if there is one.
+func (cfg config) overridden() (config, error) {
+	if cfg.file == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(cfg.file)
+	if err != nil {
+		return cfg, err
+	}
+	file := struct {
+		Timeout       *string `json:"timeout"`
+		MaxConns      *int    `json:"max_conns"`
+		IdleTimeout   *string `json:"idle_timeout"`
+		SaveInterval  *string `json:"save_interval"`
+		StatsInterval *string `json:"stats_interval"`
+	}{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return cfg, fmt.Errorf("%s: %w", cfg.file, err)
+	}
+
+	if file.MaxConns != nil {
+		cfg.maxConns = *file.MaxConns
+	}
+	for _, duration := range []struct {
+		value  *string
+		target *time.Duration
+	}{
+		{file.Timeout, &cfg.timeout},
+		{file.IdleTimeout, &cfg.idleTimeout},
+		{file.SaveInterval, &cfg.saveInterval},
+		{file.StatsInterval, &cfg.statsInterval},
+	} {
+		if duration.value == nil {
+			continue
+		}
+		if *duration.target, err = time.ParseDuration(*duration.value); err != nil {
+			return cfg, fmt.Errorf("%s: %w", cfg.file, err)
+		}
+	}
+	return cfg, nil
+}
+
+// activated returns the first socket passed by systemd socket activation, nil without one.
+func activated() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	if fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); fds < 1 {
+		return nil, nil
+	}
+	// Passed sockets start at fd 3, after stdin, stdout and stderr.
+	file := os.NewFile(3, "activated")
+	defer file.Close()
+	return net.FileListener(file)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kittenbark/nanodb/nanodbwire"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{
+		listen:       "unix://" + filepath.Join(dir, "nanodbd.sock"),
+		data:         filepath.Join(dir, "nanodb.tar"),
+		saveInterval: time.Hour,
+		drainTimeout: time.Second,
+	}
+
+	for round, action := range []func(client *nanodbwire.Client) error{
+		func(client *nanodbwire.Client) error { return client.Set("key", []byte("value")) },
+		func(client *nanodbwire.Client) error {
+			if value, ok, err := client.Get("key"); err != nil || !ok || string(value) != "value" {
+				t.Errorf("restarted daemon lost the entry: %q, %v, %v", value, ok, err)
+			}
+			return nil
+		},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- run(ctx, cfg, nil) }()
+
+		var client *nanodbwire.Client
+		var err error
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if client, err = nanodbwire.Dial("unix", filepath.Join(dir, "nanodbd.sock")); err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := action(client); err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		client.Close()
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "nanodbd.sock")); !os.IsNotExist(err) {
+			t.Errorf("round %d: socket left behind (%v)", round, err)
+		}
+	}
+}
+
+func TestRun_Reload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{
+		listen:       "unix://" + filepath.Join(dir, "nanodbd.sock"),
+		file:         filepath.Join(dir, "nanodbd.json"),
+		pid:          filepath.Join(dir, "nanodbd.pid"),
+		drainTimeout: time.Second,
+	}
+	if err := os.WriteFile(cfg.file, []byte(`{"idle_timeout": "1h"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan os.Signal)
+	done := make(chan error)
+	go func() { done <- run(ctx, cfg, reloads) }()
+
+	var client *nanodbwire.Client
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if client, err = nanodbwire.Dial("unix", filepath.Join(dir, "nanodbd.sock")); err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if pid, err := os.ReadFile(cfg.pid); err != nil || strings.TrimSpace(string(pid)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("pid file: %q, %v", pid, err)
+	}
+
+	if err := os.WriteFile(cfg.file, []byte(`{"idle_timeout": "20ms"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The second send only goes through once the first reload was applied.
+	reloads <- syscall.SIGHUP
+	reloads <- syscall.SIGHUP
+	fresh, err := nanodbwire.Dial("unix", filepath.Join(dir, "nanodbd.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fresh.Close()
+	time.Sleep(100 * time.Millisecond)
+	if _, _, err := fresh.Get("key"); err == nil {
+		t.Errorf("reloaded idle timeout not applied")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.pid); !os.IsNotExist(err) {
+		t.Errorf("pid file left behind")
+	}
+}
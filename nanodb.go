@@ -2,16 +2,27 @@ package nanodb
 
 import (
 	"iter"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 func New[T any]() *DB[T] {
-	return &DB[T]{
+	mutex := &sync.RWMutex{}
+	db := &DB[T]{
 		data:      make(map[string]T),
 		lifetimes: make(map[string]time.Time),
-		mutex:     &sync.RWMutex{},
+		mutex:     mutex,
+		expiries:  newTimers(mutex),
+		refreshes: newTimers(mutex),
+		schedules: newTimers(mutex),
+		stats:     &stats{},
 	}
+	for name, tagged := range taggedIndexes[T]() {
+		db.addIndex(name, tagged.extract, tagged.unique)
+	}
+	return db
 }
 
 type DB[T any] struct {
@@ -19,13 +30,65 @@ type DB[T any] struct {
 	lifetimes map[string]time.Time
 	timeout   time.Duration
 	mutex     *sync.RWMutex
+	expiries  *timers
+	refreshes *timers
+	schedules *timers
+	guard     Guard
+	panics    PanicPolicy
+	onError   ErrorHandler
+	loader    Loader[T]
+	resolver  Resolver[T]
+	archiver  Archiver[T]
+	notifiers []*notifier[T]
+	quotas    map[string]Quota
+	counters  *usageCounters
+	geo       *geoIndex
+	refresher *refresher[T]
+	observers []observer[T]
+	observed  int
+	batching  bool
+	batched   []change[T]
+	search    *searchIndex[T]
+	indexes   map[string]*secondaryIndex[T]
+	refs      []reference[T]
+	normalize func(key string) string
+	equal     func(a, b T) bool
+	slow      atomic.Int64
+	sequence  uint64
+	pool      *pool
+	stats     *stats
+	access    *sketch
+	adaptive  *adaptiveTTL
+	sampler   *sampler
+	xfetch    *xfetch[T]
+}
+
+// observer gets the changes of one write at a time, a batch commit is one group.
+type observer[T any] struct {
+	id      int
+	observe func(changes []change[T])
+}
+
+type change[T any] struct {
+	key     string
+	old     T
+	existed bool
+	value   T
+	exists  bool
 }
 
 func (db *DB[T]) Get(key string) T {
+	defer db.traceSlow("get", key)()
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	return db.data[key]
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) {
+		var zero T
+		return zero
+	}
+	result, _ := db.lookup(key)
+	return result
 }
 
 func (db *DB[T]) GetOr(key string, otherwise T) T {
@@ -36,40 +99,132 @@ func (db *DB[T]) GetOr(key string, otherwise T) T {
 }
 
 func (db *DB[T]) TryGet(key string) (T, bool) {
+	defer db.traceSlow("get", key)()
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	result, ok := db.data[key]
-	return result, ok
+	return db.tryGet(key)
+}
+
+// tryGet has to be called with the lock held.
+func (db *DB[T]) tryGet(key string) (T, bool) {
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) {
+		var zero T
+		return zero, false
+	}
+	return db.lookup(key)
 }
 
 func (db *DB[T]) Add(key string, value T) *DB[T] {
+	defer db.traceSlow("add", key)()
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	db.data[key] = value
-	db.lifetimes[key] = time.Now()
-	db.scheduleDel(key)
-
+	db.add(key, value)
 	return db
 }
 
+// TryAdd is Add returning a refused write instead of reporting it: the guard's error,
+// a *QuotaError, ErrDuplicate or ErrReference.
+func (db *DB[T]) TryAdd(key string, value T) error {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpAdd, key); err != nil {
+		return err
+	}
+	if err := db.admit(key, value); err != nil {
+		return err
+	}
+	db.set(key, value, time.Now())
+	return nil
+}
+
+// add has to be called with the lock held.
+func (db *DB[T]) add(key string, value T) {
+	key = db.normalized(key)
+	if !db.allowed(OpAdd, key) {
+		return
+	}
+	if err := db.admit(key, value); err != nil {
+		db.report("add", key, err)
+		return
+	}
+	db.set(key, value, time.Now())
+}
+
+// GetOrSet returns the value key holds, or stores value when it holds none.
+// A refused write is reported as "add" and returns the zero value.
+func (db *DB[T]) GetOrSet(key string, value T) (actual T, loaded bool) {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) {
+		return actual, false
+	}
+	if current, ok := db.lookup(key); ok {
+		return current, true
+	}
+	if !db.allowed(OpAdd, key) {
+		return actual, false
+	}
+	if err := db.admit(key, value); err != nil {
+		db.report("add", key, err)
+		return actual, false
+	}
+	db.set(key, value, time.Now())
+	return value, false
+}
+
 func (db *DB[T]) Del(key string) *DB[T] {
+	defer db.traceSlow("del", key)()
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	delete(db.data, key)
-	delete(db.lifetimes, key)
+	key = db.normalized(key)
+	if !db.allowed(OpDel, key) {
+		return db
+	}
+	if err := db.checkRefs(batchOp[T]{key: key, del: true}); err != nil {
+		db.report("del", key, err)
+		return db
+	}
+	db.unset(key)
 
 	return db
 }
 
+// TryDel is Del returning a refused delete instead of reporting it: the guard's error or ErrReference.
+func (db *DB[T]) TryDel(key string) error {
+	defer db.traceSlow("del", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpDel, key); err != nil {
+		return err
+	}
+	if err := db.checkRefs(batchOp[T]{key: key, del: true}); err != nil {
+		return err
+	}
+	db.unset(key)
+	return nil
+}
+
 func (db *DB[T]) Seq2() iter.Seq2[string, T] {
 	return func(yield func(string, T) bool) {
 		db.mutex.RLock()
 		defer db.mutex.RUnlock()
 
 		for key, value := range db.data {
+			if !db.allowed(OpGet, key) {
+				continue
+			}
 			if !yield(key, value) {
 				return
 			}
@@ -83,7 +238,9 @@ func (db *DB[T]) KeysSnapshot() []string {
 
 	keys := make([]string, 0, len(db.data))
 	for key := range db.data {
-		keys = append(keys, key)
+		if db.allowed(OpGet, key) {
+			keys = append(keys, key)
+		}
 	}
 	return keys
 }
@@ -95,7 +252,16 @@ func (db *DB[T]) Len() int {
 }
 
 func (db *DB[T]) Timeout(timeout time.Duration) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
 	db.timeout = timeout
+	if timeout == 0 {
+		for key := range db.lifetimes {
+			db.expiries.cancel(key)
+			db.refreshes.cancel(key)
+		}
+	}
 	for key := range db.data {
 		db.lifetimes[key] = time.Now()
 		db.scheduleDel(key)
@@ -104,18 +270,184 @@ func (db *DB[T]) Timeout(timeout time.Duration) *DB[T] {
 	return db
 }
 
+// Guard installs a permission check consulted before every keyed operation.
+// Denied reads behave as if the key is absent, denied writes are no-ops.
+func (db *DB[T]) Guard(guard Guard) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.guard = guard
+	return db
+}
+
+// Quota limits keys starting with prefix, Add calls exceeding it are dropped and logged,
+// TryAdd returns the *QuotaError.
+// Bytes are measured with encoding/json.
+func (db *DB[T]) Quota(prefix string, quota Quota) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	prefix = db.normalized(prefix)
+	if db.quotas == nil {
+		db.quotas = make(map[string]Quota)
+	}
+	db.quotas[prefix] = quota
+	db.counters = nil
+	return db
+}
+
+func (db *DB[T]) Usage(prefix string) Usage {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	prefix = db.normalized(prefix)
+	if _, ok := db.quotas[prefix]; ok && db.counters != nil {
+		return db.counters.usage[prefix]
+	}
+	return usageOf(db.data, prefix, jsonSize[T])
+}
+
+// quotaUsage has to be called with the write lock held, the counters are built on first use.
+func (db *DB[T]) quotaUsage() *usageCounters {
+	if db.counters == nil {
+		db.counters = countUsage(db.quotas, db.data, jsonSize[T])
+	}
+	return db.counters
+}
+
+// lookup has to be called with the lock held, it counts the read.
+func (db *DB[T]) lookup(key string) (T, bool) {
+	result, ok := db.data[key]
+	if ok && db.sampler != nil && db.expired(key) {
+		var zero T
+		result, ok = zero, false
+	}
+	db.stats.lookup(ok)
+	if db.access != nil {
+		db.access.add(key)
+	}
+	if ok {
+		db.fetchEarly(key)
+	}
+	return result, ok
+}
+
+// admit has to be called with the lock held, it checks a write of value to key against
+// the quotas, unique indexes and references.
+func (db *DB[T]) admit(key string, value T) error {
+	if err := checkQuotas(db.quotas, db.quotaUsage(), key, value, jsonSize[T]); err != nil {
+		return err
+	}
+	if err := db.checkUnique(batchOp[T]{key: key, value: value}); err != nil {
+		return err
+	}
+	return db.checkRefs(batchOp[T]{key: key, value: value})
+}
+
+func (db *DB[T]) allowed(op Op, key string) bool {
+	return db.check(op, key) == nil
+}
+
+func (db *DB[T]) check(op Op, key string) (err error) {
+	if db.guard == nil {
+		return nil
+	}
+	defer recoverPanic(db.panics, &err)
+	return db.guard(op, key)
+}
+
+func (db *DB[T]) set(key string, value T, lifetime time.Time) {
+	old, existed := db.data[key]
+	db.data[key] = value
+	db.lifetimes[key] = lifetime
+	db.scheduleDel(key)
+	if db.counters != nil {
+		track(db.counters, db.quotas, key, value, true, jsonSize[T])
+	}
+	if db.geo != nil {
+		db.geo.update(key, value, true)
+	}
+	db.notify(change[T]{key: key, old: old, existed: existed, value: value, exists: true})
+}
+
+func (db *DB[T]) unset(key string) {
+	old, existed := db.data[key]
+	delete(db.data, key)
+	delete(db.lifetimes, key)
+	db.expiries.cancel(key)
+	db.refreshes.cancel(key)
+	if db.counters != nil && existed {
+		track(db.counters, db.quotas, key, old, false, jsonSize[T])
+	}
+	if db.geo != nil {
+		db.geo.update(key, old, false)
+	}
+	if existed {
+		db.notify(change[T]{key: key, old: old, existed: true})
+	}
+}
+
+func (db *DB[T]) notify(changed change[T]) {
+	if db.batching {
+		db.batched = append(db.batched, changed)
+		return
+	}
+	db.publish([]change[T]{changed})
+}
+
+// publish hands a group of changes to every observer.
+func (db *DB[T]) publish(changes []change[T]) {
+	key := ""
+	if len(changes) == 1 {
+		key = changes[0].key
+	}
+	for _, observer := range db.observers {
+		if err := db.observe(observer.observe, changes); err != nil {
+			db.report("observe", key, err)
+		}
+	}
+}
+
+// addObserver has to be called with the lock held, the id removes the observer again.
+func (db *DB[T]) addObserver(observe func(changes []change[T])) int {
+	db.observed++
+	db.observers = append(db.observers, observer[T]{id: db.observed, observe: observe})
+	return db.observed
+}
+
+// removeObserver has to be called with the lock held.
+func (db *DB[T]) removeObserver(id int) {
+	db.observers = slices.DeleteFunc(db.observers, func(observer observer[T]) bool { return observer.id == id })
+}
+
+func (db *DB[T]) observe(observe func(changes []change[T]), changes []change[T]) (err error) {
+	defer recoverPanic(db.panics, &err)
+	observe(changes)
+	return nil
+}
+
 func (db *DB[T]) scheduleDel(key string) {
 	if db.timeout == 0 {
 		return
 	}
+	db.scheduleRefresh(key)
+	db.scheduleExpiry(key)
+}
 
-	time.AfterFunc(db.timeout, func() {
-		db.mutex.Lock()
-		defer db.mutex.Unlock()
+// expired has to be called with the lock held.
+func (db *DB[T]) expired(key string) bool {
+	return db.timeout != 0 && time.Since(db.lifetimes[key]) >= db.ttl(key)
+}
 
-		if time.Since(db.lifetimes[key]) >= db.timeout {
-			delete(db.data, key)
-			delete(db.lifetimes, key)
+func (db *DB[T]) scheduleExpiry(key string) {
+	if db.timeout == 0 || db.sampler != nil {
+		return
+	}
+	db.expiries.schedule(key, db.ttl(key)-time.Since(db.lifetimes[key]), func() {
+		if db.expired(key) {
+			db.evict(key)
+		} else {
+			db.scheduleExpiry(key)
 		}
 	})
 }
@@ -0,0 +1,135 @@
+package nanodb
+
+import (
+	"cmp"
+	"hash/maphash"
+	"slices"
+	"sync/atomic"
+)
+
+const sketchDepth = 4
+
+// sketch is a count-min sketch of key accesses, memory stays at width*4 counters however many keys there are.
+// Counters are halved every 10*width accesses so old traffic fades out.
+type sketch struct {
+	seeds    [sketchDepth]maphash.Seed
+	counters [sketchDepth][]atomic.Uint32
+	accesses atomic.Uint64
+	width    uint64
+}
+
+func newSketch(width int) *sketch {
+	s := &sketch{width: uint64(max(width, 16))}
+	for row := range sketchDepth {
+		s.seeds[row] = maphash.MakeSeed()
+		s.counters[row] = make([]atomic.Uint32, s.width)
+	}
+	return s
+}
+
+func (s *sketch) add(key string) {
+	for row := range sketchDepth {
+		s.counters[row][maphash.String(s.seeds[row], key)%s.width].Add(1)
+	}
+	if s.accesses.Add(1)%(10*s.width) == 0 {
+		s.age()
+	}
+}
+
+func (s *sketch) count(key string) uint64 {
+	count := uint32(0)
+	for row := range sketchDepth {
+		value := s.counters[row][maphash.String(s.seeds[row], key)%s.width].Load()
+		if row == 0 || value < count {
+			count = value
+		}
+	}
+	return uint64(count)
+}
+
+func (s *sketch) age() {
+	for row := range sketchDepth {
+		for i := range s.counters[row] {
+			counter := &s.counters[row][i]
+			for {
+				value := counter.Load()
+				if counter.CompareAndSwap(value, value/2) {
+					break
+				}
+			}
+		}
+	}
+}
+
+type KeyCount struct {
+	Key   string
+	Count uint64
+}
+
+// TrackAccess counts reads per key in a count-min sketch of width columns, for Hottest and Coldest.
+// Counts are estimates, they may be overstated but never understated.
+func (db *DB[T]) TrackAccess(width int) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.access = newSketch(width)
+	return db
+}
+
+// Hottest returns up to n most read keys, nil unless TrackAccess was called.
+func (db *DB[T]) Hottest(n int) []KeyCount {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return ranked(db.access, db.data, n, func(a, b KeyCount) int { return cmp.Compare(b.Count, a.Count) })
+}
+
+// Coldest returns up to n least read keys, nil unless TrackAccess was called.
+func (db *DB[T]) Coldest(n int) []KeyCount {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return ranked(db.access, db.data, n, func(a, b KeyCount) int { return cmp.Compare(a.Count, b.Count) })
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) TrackAccess(width int) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.access = newSketch(width)
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Hottest(n int) ([]KeyCount, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+	return ranked(db.access, db.data, n, func(a, b KeyCount) int { return cmp.Compare(b.Count, a.Count) }), nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Coldest(n int) ([]KeyCount, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+	return ranked(db.access, db.data, n, func(a, b KeyCount) int { return cmp.Compare(a.Count, b.Count) }), nil
+}
+
+func ranked[T any](access *sketch, data map[string]T, n int, order func(a, b KeyCount) int) []KeyCount {
+	if access == nil {
+		return nil
+	}
+	counts := make([]KeyCount, 0, len(data))
+	for key := range data {
+		counts = append(counts, KeyCount{Key: key, Count: access.count(key)})
+	}
+	slices.SortFunc(counts, func(a, b KeyCount) int {
+		return cmp.Or(order(a, b), cmp.Compare(a.Key, b.Key))
+	})
+	return counts[:min(n, len(counts))]
+}
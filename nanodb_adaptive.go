@@ -0,0 +1,40 @@
+package nanodb
+
+import (
+	"math/bits"
+	"time"
+)
+
+type adaptiveTTL struct {
+	min, max time.Duration
+}
+
+// AdaptiveTTL replaces the single timeout with a per-key one between min and max:
+// a key never read lives min, and its lifetime doubles every time its read count doubles.
+// Counts come from TrackAccess, which is enabled with a default width if needed.
+// Entries still only expire while a non-zero Timeout is set.
+func (db *DB[T]) AdaptiveTTL(min, max time.Duration) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.access == nil {
+		db.access = newSketch(1024)
+	}
+	db.adaptive = &adaptiveTTL{min: min, max: max}
+	for key := range db.data {
+		db.scheduleExpiry(key)
+	}
+	return db
+}
+
+// ttl has to be called with the lock held.
+func (db *DB[T]) ttl(key string) time.Duration {
+	if db.adaptive == nil || db.timeout == 0 {
+		return db.timeout
+	}
+	doublings := bits.Len64(db.access.count(key))
+	if doublings >= 63 || db.adaptive.min<<doublings > db.adaptive.max || db.adaptive.min<<doublings <= 0 {
+		return db.adaptive.max
+	}
+	return db.adaptive.min << doublings
+}
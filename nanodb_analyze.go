@@ -0,0 +1,128 @@
+package nanodb
+
+import (
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Report describes the shape of the stored data, see Analyze.
+type Report struct {
+	Keys int
+	// Prefixes counts keys by their first segment up to and including '/' or ':'.
+	Prefixes map[string]int
+	Sizes    Distribution[int]
+	// Compression is the compressed to encoded size ratio of all values, lower compresses better.
+	Compression float64
+	// TTL is the distribution of the remaining lifetimes, zero without a timeout.
+	TTL Distribution[time.Duration]
+}
+
+type Distribution[N int | time.Duration] struct {
+	Min, P50, P90, P99, Max N
+}
+
+func (report Report) String() string {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "keys: %d\n", report.Keys)
+	prefixes := make([]string, 0, len(report.Prefixes))
+	for prefix := range report.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.SortFunc(prefixes, func(a, b string) int { return report.Prefixes[b] - report.Prefixes[a] })
+	for _, prefix := range prefixes {
+		fmt.Fprintf(out, "  %-24q %d\n", prefix, report.Prefixes[prefix])
+	}
+	fmt.Fprintf(out, "value bytes: %+v\n", report.Sizes)
+	fmt.Fprintf(out, "compression ratio: %.2f\n", report.Compression)
+	fmt.Fprintf(out, "remaining ttl: %+v\n", report.TTL)
+	return out.String()
+}
+
+func (report Report) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("keys", report.Keys),
+		slog.Int("prefixes", len(report.Prefixes)),
+		slog.Int("size_p50", report.Sizes.P50),
+		slog.Int("size_max", report.Sizes.Max),
+		slog.Float64("compression", report.Compression),
+		slog.Duration("ttl_p50", report.TTL.P50),
+	)
+}
+
+// Analyze summarises keys, value sizes, compressibility and TTLs to help choose storage options.
+// Sizes are measured with encoding/json.
+func (db *DB[T]) Analyze() Report {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return analyze(db.data, db.lifetimes, db.timeout, func(w io.Writer, value T) error {
+		return json.NewEncoder(w).Encode(value)
+	})
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Analyze() (Report, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		return Report{}, err
+	}
+	return analyze(db.data, db.lifetimes, db.timeout, func(w io.Writer, value T) error {
+		return db.newEncoder(w).Encode(value)
+	}), nil
+}
+
+func analyze[T any](
+	data map[string]T,
+	lifetimes map[string]time.Time,
+	timeout time.Duration,
+	encode func(w io.Writer, value T) error,
+) Report {
+	report := Report{Keys: len(data), Prefixes: make(map[string]int)}
+	sizes := make([]int, 0, len(data))
+	ttls := make([]time.Duration, 0, len(data))
+	encoded, compressed := 0, countingWriter(0)
+	compressor, _ := flate.NewWriter(&compressed, flate.BestSpeed)
+
+	for key, value := range data {
+		report.Prefixes[prefixOf(key)]++
+		if timeout != 0 {
+			ttls = append(ttls, max(0, time.Until(lifetimes[key].Add(timeout))))
+		}
+		size := countingWriter(0)
+		if err := encode(io.MultiWriter(&size, compressor), value); err == nil {
+			sizes = append(sizes, int(size))
+			encoded += int(size)
+		}
+	}
+	_ = compressor.Close()
+
+	report.Sizes = distribution(sizes)
+	report.TTL = distribution(ttls)
+	if encoded > 0 {
+		report.Compression = float64(compressed) / float64(encoded)
+	}
+	return report
+}
+
+func prefixOf(key string) string {
+	if i := strings.IndexAny(key, "/:"); i >= 0 {
+		return key[:i+1]
+	}
+	return ""
+}
+
+func distribution[N int | time.Duration](values []N) Distribution[N] {
+	if len(values) == 0 {
+		return Distribution[N]{}
+	}
+	slices.Sort(values)
+	at := func(q float64) N { return values[int(q*float64(len(values)-1))] }
+	return Distribution[N]{Min: values[0], P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: values[len(values)-1]}
+}
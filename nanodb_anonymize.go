@@ -0,0 +1,64 @@
+package nanodb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+const anonymizeSeparators = "/:."
+
+// ExportAnonymized writes the data as JSON with every key segment replaced by a salted hash,
+// keys sharing a prefix keep sharing the hashed prefix. Values are replaced with the result of transform.
+// Keys the guard refuses to read are left out.
+func (db *DB[T]) ExportAnonymized(w io.Writer, salt []byte, transform func(key string, value T) any) error {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, _ := readable(db.data, db.lifetimes, db.check)
+	return json.NewEncoder(w).Encode(anonymize(data, salt, transform))
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) ExportAnonymized(w io.Writer, salt []byte, transform func(key string, value T) any) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		return err
+	}
+	data, _ := readable(db.data, db.lifetimes, db.check)
+	return db.newEncoder(w).Encode(anonymize(data, salt, transform))
+}
+
+func anonymize[T any](data map[string]T, salt []byte, transform func(key string, value T) any) map[string]any {
+	result := make(map[string]any, len(data))
+	for key, value := range data {
+		result[AnonymizeKey(salt, key)] = transform(key, value)
+	}
+	return result
+}
+
+// AnonymizeKey hashes every segment of the key separated by '/', ':' or '.', keeping the separators.
+func AnonymizeKey(salt []byte, key string) string {
+	builder := strings.Builder{}
+	for len(key) > 0 {
+		end := strings.IndexAny(key, anonymizeSeparators)
+		if end == -1 {
+			end = len(key)
+		}
+		if end > 0 {
+			mac := hmac.New(sha256.New, salt)
+			mac.Write([]byte(key[:end]))
+			builder.WriteString(hex.EncodeToString(mac.Sum(nil))[:12])
+		}
+		if end < len(key) {
+			builder.WriteByte(key[end])
+			end++
+		}
+		key = key[end:]
+	}
+	return builder.String()
+}
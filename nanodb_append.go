@@ -0,0 +1,94 @@
+package nanodb
+
+import (
+	"errors"
+	"io/fs"
+	"iter"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Append stores value under the next free id and returns it. Ids count up from the highest id
+// among the keys on the first Append, skipping ids taken since by other writes.
+// Keys are the id in decimal, as Keyed[uint64, T] expects.
+// A refused write still uses up its id and is reported as "add".
+func (db *DB[T]) Append(value T) (id uint64) {
+	defer db.traceSlow("add", "")()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.sequence == 0 {
+		db.sequence = highestID(maps.Keys(db.data))
+	}
+	db.sequence = nextID(db.sequence, func(key string) bool {
+		_, ok := db.data[key]
+		return ok
+	})
+	db.add(strconv.FormatUint(db.sequence, 10), value)
+	return db.sequence
+}
+
+func (keyed *Keyed[K, V]) Append(value V) K {
+	key, _ := parseKey[K](strconv.FormatUint(keyed.db.Append(value), 10))
+	return key
+}
+
+// Append stores and saves value under the next free id, see DB.Append. The last id is kept
+// in a file next to the cache, named after it with ".seq" appended, so ids are never reused,
+// not even those of deleted entries. The keys are only scanned while that file is missing.
+func (db *DBCache[T, EncoderT, DecoderT]) Append(value T) (uint64, error) {
+	defer db.traceSlow("add", "")()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.throttle(); err != nil {
+		return 0, err
+	}
+	if err := db.loadForWrite(); err != nil {
+		return 0, err
+	}
+	id, err := db.readSequence()
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		id = highestID(db.keys())
+	}
+	id = nextID(id, db.has)
+	if err := writeFile(db.fsys, db.cache+".seq", []byte(strconv.FormatUint(id, 10))); err != nil {
+		return 0, err
+	}
+	return id, db.add(strconv.FormatUint(id, 10), value)
+}
+
+// readSequence has to be called with the lock held, a missing file counts as no ids handed out.
+func (db *DBCache[T, EncoderT, DecoderT]) readSequence() (uint64, error) {
+	data, err := fs.ReadFile(db.fsys, db.cache+".seq")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// nextID returns the first id above last whose key is not taken.
+func nextID(last uint64, taken func(key string) bool) uint64 {
+	id := last + 1
+	for taken(strconv.FormatUint(id, 10)) {
+		id++
+	}
+	return id
+}
+
+func highestID(keys iter.Seq[string]) uint64 {
+	highest := uint64(0)
+	for key := range keys {
+		if id, err := strconv.ParseUint(key, 10, 64); err == nil {
+			highest = max(highest, id)
+		}
+	}
+	return highest
+}
@@ -0,0 +1,150 @@
+package nanodb
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const archiveVersion = 1
+
+var ErrArchive = errors.New("nanodb: malformed archive")
+
+type archiveMeta struct {
+	Version   int                  `json:"version"`
+	Timeout   time.Duration        `json:"timeout"`
+	Lifetimes map[string]time.Time `json:"lifetimes"`
+}
+
+// ExportArchive writes a tar archive with the data, the TTL metadata and the format version.
+// Keys the guard does not let be read are left out, like with Seq2.
+func (db *DB[T]) ExportArchive(w io.Writer) error {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, lifetimes := readable(db.data, db.lifetimes, db.check)
+	return writeArchive(w, archiveMeta{Timeout: db.timeout, Lifetimes: lifetimes}, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(data)
+	})
+}
+
+// ImportArchive merges an archive produced by ExportArchive, entries keep their original lifetimes.
+// An archive writing a key the guard refuses, or breaking a quota, a unique index or a reference,
+// is refused as a whole with that error.
+func (db *DB[T]) ImportArchive(r io.Reader) error {
+	data := make(map[string]T)
+	meta, err := readArchive(r, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&data)
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.restore(data, meta.Lifetimes)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) ExportArchive(w io.Writer) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		return err
+	}
+	data, lifetimes := readable(db.data, db.lifetimes, db.check)
+	return writeArchive(w, archiveMeta{Timeout: db.timeout, Lifetimes: lifetimes}, func(w io.Writer) error {
+		return db.newEncoder(w).Encode(data)
+	})
+}
+
+// readable copies the entries and lifetimes of the keys check lets be read.
+func readable[T any](data map[string]T, lifetimes map[string]time.Time, check func(op Op, key string) error) (map[string]T, map[string]time.Time) {
+	visible, visibleLifetimes := make(map[string]T, len(data)), make(map[string]time.Time, len(data))
+	for key, value := range data {
+		if check(OpGet, key) == nil {
+			visible[key], visibleLifetimes[key] = value, lifetimes[key]
+		}
+	}
+	return visible, visibleLifetimes
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) ImportArchive(r io.Reader) error {
+	data := make(map[string]T)
+	meta, err := readArchive(r, func(r io.Reader) error {
+		return db.newDecoder(r).Decode(&data)
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.restore(data, meta.Lifetimes)
+}
+
+func writeArchive(w io.Writer, meta archiveMeta, encode func(io.Writer) error) error {
+	data := &bytes.Buffer{}
+	if err := encode(data); err != nil {
+		return err
+	}
+	meta.Version = archiveVersion
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	archive := tar.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		body []byte
+	}{
+		{"meta.json", metaData},
+		{"data", data.Bytes()},
+	} {
+		header := &tar.Header{Name: file.name, Mode: 0666, Size: int64(len(file.body)), ModTime: time.Now()}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(file.body); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func readArchive(r io.Reader, decode func(io.Reader) error) (archiveMeta, error) {
+	meta := archiveMeta{}
+	archive := tar.NewReader(r)
+	seenMeta, seenData := false, false
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return meta, err
+		}
+
+		switch header.Name {
+		case "meta.json":
+			if err := json.NewDecoder(archive).Decode(&meta); err != nil {
+				return meta, err
+			}
+			if meta.Version != archiveVersion {
+				return meta, fmt.Errorf("%w: unsupported version %d", ErrArchive, meta.Version)
+			}
+			seenMeta = true
+		case "data":
+			if err := decode(archive); err != nil {
+				return meta, err
+			}
+			seenData = true
+		}
+	}
+
+	if !seenMeta || !seenData {
+		return meta, fmt.Errorf("%w: missing meta.json or data", ErrArchive)
+	}
+	return meta, nil
+}
@@ -0,0 +1,46 @@
+package nanodb
+
+// Archiver receives entries removed by the timeout or a CleanupPolicy instead of them being dropped.
+type Archiver[T any] func(key string, value T, meta EntryMeta) error
+
+// Archive routes expired and cleaned up entries to archiver, explicit Del calls are not archived.
+// The archiver runs outside of the lock on the worker pool, its errors are reported as "archive".
+func (db *DB[T]) Archive(archiver Archiver[T]) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.archiver = archiver
+	return db
+}
+
+// ArchiveTo is an Archiver writing into another store, e.g. a DBCache as the cold tier of a DB.
+func ArchiveTo[T any](store Store[T]) Archiver[T] {
+	return func(key string, value T, _ EntryMeta) error {
+		return store.update(key, func(T, bool) (T, Op) { return value, OpAdd })
+	}
+}
+
+// evict has to be called with the lock held, it removes the entry and hands it to the archiver.
+func (db *DB[T]) evict(key string) {
+	value, ok := db.data[key]
+	if !ok {
+		return
+	}
+	meta := EntryMeta{Modified: db.lifetimes[key], Expires: expiresAt(db.ttl(key), db.lifetimes[key])}
+	db.unset(key)
+
+	archiver := db.archiver
+	if archiver == nil && len(db.notifiers) == 0 {
+		return
+	}
+	meta.Size = jsonSize(value)
+	db.notifyExpired(Expired[T]{Key: key, Value: value, Meta: meta})
+	if archiver == nil {
+		return
+	}
+	db.workerPool().submit(func() {
+		if err := archiver(key, value, meta); err != nil {
+			db.report("archive", key, err)
+		}
+	})
+}
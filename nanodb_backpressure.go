@@ -0,0 +1,47 @@
+package nanodb
+
+import "errors"
+
+var ErrBusy = errors.New("nanodb: too many unsaved writes")
+
+type backpressure struct {
+	limit int
+	block bool
+}
+
+// Backpressure bounds the writes not saved yet: those waiting for a save in Strict and
+// ReadOwnWrites mode, those waiting for the next sync in Relaxed mode. A write arriving at the
+// limit waits for a save, in Relaxed mode it saves right away, or fails with ErrBusy when block
+// is false. A limit of 0 removes the bound.
+func (db *DBCache[T, EncoderT, DecoderT]) Backpressure(limit int, block bool) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.backpressure = backpressure{limit: limit, block: block}
+	return db
+}
+
+// throttle has to be called with the lock held before a write, it may release the lock while waiting.
+func (db *DBCache[T, EncoderT, DecoderT]) throttle() error {
+	if db.backpressure.limit <= 0 {
+		return nil
+	}
+	for db.unsaved() >= db.backpressure.limit {
+		if !db.backpressure.block {
+			return ErrBusy
+		}
+		if db.consistency == Relaxed {
+			return db.sync()
+		}
+		db.commits.done.Wait()
+	}
+	return nil
+}
+
+// unsaved has to be called with the lock held.
+func (db *DBCache[T, EncoderT, DecoderT]) unsaved() int {
+	if db.consistency == Relaxed {
+		return db.syncer.unsaved
+	}
+	return int(db.commits.requested - db.commits.saved)
+}
@@ -0,0 +1,152 @@
+package nanodb
+
+import (
+	"time"
+)
+
+// Batch collects Add and Del calls until Commit applies all of them at once or none of them.
+type Batch[T any] struct {
+	ops    []batchOp[T]
+	commit func(ops []batchOp[T]) error
+}
+
+type batchOp[T any] struct {
+	key   string
+	value T
+	del   bool
+}
+
+func (op batchOp[T]) op() Op {
+	if op.del {
+		return OpDel
+	}
+	return OpAdd
+}
+
+// Batch starts an empty batch, observers see its changes applied under a single lock
+// and get them as one group, Watch callbacks run for them in order from a single pooled task.
+func (db *DB[T]) Batch() *Batch[T] {
+	return &Batch[T]{commit: db.commit}
+}
+
+// Batch starts an empty batch, its changes are written with a single save.
+func (db *DBCache[T, EncoderT, DecoderT]) Batch() *Batch[T] {
+	return &Batch[T]{commit: db.commit}
+}
+
+func (batch *Batch[T]) Add(key string, value T) *Batch[T] {
+	batch.ops = append(batch.ops, batchOp[T]{key: key, value: value})
+	return batch
+}
+
+func (batch *Batch[T]) Del(key string) *Batch[T] {
+	batch.ops = append(batch.ops, batchOp[T]{key: key, del: true})
+	return batch
+}
+
+func (batch *Batch[T]) Len() int {
+	return len(batch.ops)
+}
+
+// Commit applies the operations in order. A denied operation or an exceeded quota fails
+// the whole batch before anything changed, a committed batch is empty again and can be reused.
+func (batch *Batch[T]) Commit() error {
+	if len(batch.ops) == 0 {
+		return nil
+	}
+	if err := batch.commit(batch.ops); err != nil {
+		return err
+	}
+	batch.ops = nil
+	return nil
+}
+
+func (db *DB[T]) commit(ops []batchOp[T]) error {
+	defer db.traceSlow("batch", "")()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	ops = normalizedOps(ops, db.normalize)
+	if err := stage(ops, db.check, db.quotas, db.quotaUsage(), jsonSize[T]); err != nil {
+		return err
+	}
+	if err := db.checkUnique(ops...); err != nil {
+		return err
+	}
+	if err := db.checkRefs(ops...); err != nil {
+		return err
+	}
+	now := time.Now()
+	db.batching = true
+	for _, op := range ops {
+		if op.del {
+			db.unset(op.key)
+		} else {
+			db.set(op.key, op.value, now)
+		}
+	}
+	changes := db.batched
+	db.batching, db.batched = false, nil
+	if len(changes) > 0 {
+		db.publish(changes)
+	}
+	return nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) commit(ops []batchOp[T]) error {
+	defer db.traceSlow("batch", "")()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	ops = normalizedOps(ops, db.normalize)
+	if err := db.throttle(); err != nil {
+		return err
+	}
+	if err := db.loadForWrite(); err != nil {
+		return err
+	}
+	if err := db.loadQuotas(); err != nil {
+		return err
+	}
+	if err := stage(ops, db.check, db.quotas, db.quotaUsage(), db.size); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, op := range ops {
+		if op.del {
+			db.unset(op.key)
+			continue
+		}
+		db.set(op.key, op.value, now)
+	}
+	return db.save()
+}
+
+// stage checks every operation against the guard and the quotas without applying any of them.
+func stage[T any](
+	ops []batchOp[T],
+	check func(op Op, key string) error,
+	quotas map[string]Quota,
+	counters *usageCounters,
+	size func(T) int,
+) error {
+	for _, op := range ops {
+		if err := check(op.op(), op.key); err != nil {
+			return err
+		}
+	}
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	staged := counters.staged()
+	for _, op := range ops {
+		if !op.del {
+			if err := checkQuotas(quotas, staged, op.key, op.value, size); err != nil {
+				return err
+			}
+		}
+		track(staged, quotas, op.key, op.value, !op.del, size)
+	}
+	return nil
+}
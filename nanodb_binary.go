@@ -0,0 +1,125 @@
+package nanodb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrBinary = errors.New("nanodb: malformed binary encoding")
+
+// maxBinaryChunk keeps a corrupted length prefix from allocating the machine away.
+const maxBinaryChunk = 1 << 31
+
+// BinaryEncoder writes []byte values and map[string][]byte caches as length-prefixed records,
+// use it with Fromf[[]byte] to store blobs without the base64 inflation of JSON.
+type BinaryEncoder struct {
+	w io.Writer
+}
+
+func NewBinaryEncoder(w io.Writer) *BinaryEncoder {
+	return &BinaryEncoder{w: w}
+}
+
+func (encoder *BinaryEncoder) Encode(v any) error {
+	w := bufio.NewWriter(encoder.w)
+	switch v := v.(type) {
+	case []byte:
+		writeChunk(w, v)
+	case map[string][]byte:
+		writeUvarint(w, uint64(len(v)))
+		for key, value := range v {
+			writeChunk(w, []byte(key))
+			writeChunk(w, value)
+		}
+	default:
+		return fmt.Errorf("nanodb: BinaryEncoder cannot encode %T", v)
+	}
+	return w.Flush()
+}
+
+type BinaryDecoder struct {
+	r *bufio.Reader
+}
+
+func NewBinaryDecoder(r io.Reader) *BinaryDecoder {
+	return &BinaryDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads into a *[]byte or a *map[string][]byte, a nil map is allocated and
+// the decoded entries are added to the ones already there.
+func (decoder *BinaryDecoder) Decode(v any) error {
+	switch v := v.(type) {
+	case *[]byte:
+		value, err := readChunk(decoder.r)
+		if err != nil {
+			return err
+		}
+		*v = value
+		return nil
+	case *map[string][]byte:
+		n, err := binary.ReadUvarint(decoder.r)
+		if err != nil {
+			return binaryError(err)
+		}
+		if *v == nil {
+			*v = make(map[string][]byte)
+		}
+		for range n {
+			key, err := readChunk(decoder.r)
+			if err != nil {
+				return err
+			}
+			value, err := readChunk(decoder.r)
+			if err != nil {
+				return err
+			}
+			(*v)[string(key)] = value
+		}
+		return nil
+	default:
+		return fmt.Errorf("nanodb: BinaryDecoder cannot decode into %T", v)
+	}
+}
+
+func writeUvarint(w *bufio.Writer, n uint64) {
+	w.Write(binary.AppendUvarint(nil, n))
+}
+
+func writeChunk(w *bufio.Writer, chunk []byte) {
+	writeUvarint(w, uint64(len(chunk)))
+	w.Write(chunk)
+}
+
+func readChunk(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, truncated(err)
+	}
+	if n > maxBinaryChunk {
+		return nil, fmt.Errorf("%w: %d byte chunk", ErrBinary, n)
+	}
+	chunk := make([]byte, n)
+	if _, err := io.ReadFull(r, chunk); err != nil {
+		return nil, truncated(err)
+	}
+	return chunk, nil
+}
+
+// binaryError keeps io.EOF for an empty input and reports everything else as ErrBinary.
+func binaryError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrBinary, err)
+}
+
+// truncated reports an input ending inside a record.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%w: %w", ErrBinary, err)
+}
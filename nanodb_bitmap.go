@@ -0,0 +1,85 @@
+package nanodb
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// SetBit sets or clears the bit at offset of a []byte value, growing it as needed,
+// and returns the previous bit. Bits are numbered from the most significant bit of the first byte.
+func SetBit(db Store[[]byte], key string, offset uint, value bool) (previous bool, err error) {
+	err = db.update(key, func(bitmap []byte, _ bool) ([]byte, Op) {
+		index, mask := offset/8, byte(0x80>>(offset%8))
+		if int(index) >= len(bitmap) {
+			bitmap = append(bitmap, make([]byte, int(index)+1-len(bitmap))...)
+		} else {
+			bitmap = slices.Clone(bitmap)
+		}
+
+		previous = bitmap[index]&mask != 0
+		if value {
+			bitmap[index] |= mask
+		} else {
+			bitmap[index] &^= mask
+		}
+		return bitmap, OpAdd
+	})
+	return
+}
+
+func GetBit(db Store[[]byte], key string, offset uint) (bool, error) {
+	bitmap, _, err := db.read(key)
+	if err != nil || int(offset/8) >= len(bitmap) {
+		return false, err
+	}
+	return bitmap[offset/8]&(0x80>>(offset%8)) != 0, nil
+}
+
+func CountBits(db Store[[]byte], key string) (int, error) {
+	bitmap, _, err := db.read(key)
+	count := 0
+	for _, b := range bitmap {
+		count += bits.OnesCount8(b)
+	}
+	return count, err
+}
+
+// AndNot stores a &^ b into dst. The sources are read one by one, so the result
+// is not atomic with respect to concurrent writes to a or b.
+func AndNot(db Store[[]byte], dst, a, b string) error {
+	return bitop(db, dst, a, b, func(x, y byte) byte { return x &^ y })
+}
+
+func BitAnd(db Store[[]byte], dst, a, b string) error {
+	return bitop(db, dst, a, b, func(x, y byte) byte { return x & y })
+}
+
+func BitOr(db Store[[]byte], dst, a, b string) error {
+	return bitop(db, dst, a, b, func(x, y byte) byte { return x | y })
+}
+
+func bitop(db Store[[]byte], dst, a, b string, op func(x, y byte) byte) error {
+	left, _, err := db.read(a)
+	if err != nil {
+		return err
+	}
+	right, _, err := db.read(b)
+	if err != nil {
+		return err
+	}
+
+	result := make([]byte, max(len(left), len(right)))
+	for i := range result {
+		var x, y byte
+		if i < len(left) {
+			x = left[i]
+		}
+		if i < len(right) {
+			y = right[i]
+		}
+		result[i] = op(x, y)
+	}
+	return db.update(dst, func([]byte, bool) ([]byte, Op) {
+		return result, OpAdd
+	})
+}
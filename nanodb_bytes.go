@@ -0,0 +1,266 @@
+package nanodb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/maphash"
+	"io"
+	"iter"
+	"sync"
+)
+
+// Bytes is a store for []byte values copied with their keys into a single arena instead of
+// one allocation each. The index maps key hashes to arena offsets and holds no pointers, so the
+// garbage collector skips it however many entries there are; only keys whose hash collides with
+// another key go to a small regular map. Overwritten and deleted entries stay in the arena
+// until they make up half of it, then it is compacted.
+type Bytes struct {
+	mutex      *sync.RWMutex
+	hash       func(key string) uint64
+	index      map[uint64]span
+	collisions map[string]span
+	arena      []byte
+	garbage    int
+}
+
+// span is a key followed by its value in the arena.
+type span struct {
+	offset int
+	key    int
+	value  int
+}
+
+func NewBytes() *Bytes {
+	seed := maphash.MakeSeed()
+	return &Bytes{
+		mutex:      &sync.RWMutex{},
+		hash:       func(key string) uint64 { return maphash.String(seed, key) },
+		index:      make(map[uint64]span),
+		collisions: make(map[string]span),
+	}
+}
+
+// Grow preallocates the arena for n more bytes of keys and values.
+func (db *Bytes) Grow(n int) *Bytes {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if n > cap(db.arena)-len(db.arena) {
+		arena := make([]byte, len(db.arena), len(db.arena)+n)
+		copy(arena, db.arena)
+		db.arena = arena
+	}
+	return db
+}
+
+// Get returns a copy of the value, nil when the key is missing.
+func (db *Bytes) Get(key string) []byte {
+	value, _ := db.TryGet(key)
+	return value
+}
+
+func (db *Bytes) TryGet(key string) ([]byte, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	at, ok := db.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return append([]byte{}, db.view(at)...), true
+}
+
+// GetFunc calls fn with the value in place, without copying it. The slice is only valid
+// during the call, fn must not keep it, modify it or call back into db.
+func (db *Bytes) GetFunc(key string, fn func(value []byte)) bool {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	at, ok := db.lookup(key)
+	if ok {
+		fn(db.view(at))
+	}
+	return ok
+}
+
+// Add copies key and value into the arena.
+func (db *Bytes) Add(key string, value []byte) *Bytes {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.set(key, value)
+	return db
+}
+
+func (db *Bytes) Del(key string) *Bytes {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if at, ok := db.remove(key); ok {
+		db.discard(at)
+	}
+	return db
+}
+
+func (db *Bytes) Len() int {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return len(db.index) + len(db.collisions)
+}
+
+// Seq2 yields the values in place like GetFunc, copy them to keep them past the iteration.
+func (db *Bytes) Seq2() iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		db.mutex.RLock()
+		defer db.mutex.RUnlock()
+
+		for at := range db.spans() {
+			if !yield(string(db.keyOf(at)), db.view(at)) {
+				return
+			}
+		}
+	}
+}
+
+// WriteTo writes every entry in the BinaryEncoder format, so the output can also be opened
+// with Fromf[[]byte] and NewBinaryDecoder.
+func (db *Bytes) WriteTo(w io.Writer) (int64, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	counter := countingWriter(0)
+	buffered := bufio.NewWriter(io.MultiWriter(w, &counter))
+	writeUvarint(buffered, uint64(len(db.index)+len(db.collisions)))
+	for at := range db.spans() {
+		writeChunk(buffered, db.keyOf(at))
+		writeChunk(buffered, db.view(at))
+	}
+	err := buffered.Flush()
+	return int64(counter), err
+}
+
+// ReadFrom adds the entries written by WriteTo or a BinaryEncoder to db, an empty r adds nothing.
+// It returns the bytes it consumed, which may be fewer than it read from r.
+func (db *Bytes) ReadFrom(r io.Reader) (int64, error) {
+	counter := countingWriter(0)
+	buffered := bufio.NewReader(io.TeeReader(r, &counter))
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	n, err := binary.ReadUvarint(buffered)
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return int64(counter), binaryError(err)
+	}
+	for range n {
+		key, err := readChunk(buffered)
+		if err != nil {
+			return int64(counter), err
+		}
+		value, err := readChunk(buffered)
+		if err != nil {
+			return int64(counter), err
+		}
+		db.set(string(key), value)
+	}
+	return int64(counter) - int64(buffered.Buffered()), nil
+}
+
+// keyOf has to be called with the lock held.
+func (db *Bytes) keyOf(at span) []byte {
+	end := at.offset + at.key
+	return db.arena[at.offset:end:end]
+}
+
+// view has to be called with the lock held.
+func (db *Bytes) view(at span) []byte {
+	start, end := at.offset+at.key, at.offset+at.key+at.value
+	return db.arena[start:end:end]
+}
+
+// lookup has to be called with the lock held.
+func (db *Bytes) lookup(key string) (span, bool) {
+	if at, ok := db.index[db.hash(key)]; ok && string(db.keyOf(at)) == key {
+		return at, true
+	}
+	at, ok := db.collisions[key]
+	return at, ok
+}
+
+// spans has to be called with the lock held.
+func (db *Bytes) spans() iter.Seq[span] {
+	return func(yield func(span) bool) {
+		for _, at := range db.index {
+			if !yield(at) {
+				return
+			}
+		}
+		for _, at := range db.collisions {
+			if !yield(at) {
+				return
+			}
+		}
+	}
+}
+
+// remove has to be called with the lock held, a collision with the same hash takes over the freed slot.
+func (db *Bytes) remove(key string) (span, bool) {
+	hash := db.hash(key)
+	if at, ok := db.index[hash]; ok && string(db.keyOf(at)) == key {
+		delete(db.index, hash)
+		for other, moved := range db.collisions {
+			if db.hash(other) == hash {
+				delete(db.collisions, other)
+				db.index[hash] = moved
+				break
+			}
+		}
+		return at, true
+	}
+	at, ok := db.collisions[key]
+	delete(db.collisions, key)
+	return at, ok
+}
+
+// set has to be called with the lock held.
+func (db *Bytes) set(key string, value []byte) {
+	if at, ok := db.remove(key); ok {
+		db.discard(at)
+	}
+	db.place(key, span{offset: len(db.arena), key: len(key), value: len(value)})
+	db.arena = append(append(db.arena, key...), value...)
+}
+
+// place has to be called with the lock held.
+func (db *Bytes) place(key string, at span) {
+	hash := db.hash(key)
+	if _, taken := db.index[hash]; taken {
+		db.collisions[key] = at
+		return
+	}
+	db.index[hash] = at
+}
+
+// discard has to be called with the lock held, it compacts the arena once half of it is garbage.
+func (db *Bytes) discard(at span) {
+	db.garbage += at.key + at.value
+	if db.garbage*2 < len(db.arena) {
+		return
+	}
+
+	arena := make([]byte, 0, len(db.arena)-db.garbage)
+	for hash, at := range db.index {
+		db.index[hash] = span{offset: len(arena), key: at.key, value: at.value}
+		arena = append(arena, db.arena[at.offset:at.offset+at.key+at.value]...)
+	}
+	for key, at := range db.collisions {
+		db.collisions[key] = span{offset: len(arena), key: at.key, value: at.value}
+		arena = append(arena, db.arena[at.offset:at.offset+at.key+at.value]...)
+	}
+	db.arena, db.garbage = arena, 0
+}
@@ -1,12 +1,14 @@
 package nanodb
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"io/fs"
 	"iter"
-	"log/slog"
-	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,97 +26,236 @@ func Fromf[T any, EncoderT Encoder, DecoderT Decoder](
 	filename string,
 	encoder NewEncoder[EncoderT],
 	decoder NewDecoder[DecoderT],
+	opts ...Option,
 ) (*DBCache[T, EncoderT, DecoderT], error) {
+	options := collectOptions(opts)
+	overrides := envOverrides{}
+	if options.envPrefix != "" {
+		var err error
+		if overrides, err = readEnv(options.envPrefix); err != nil {
+			return nil, err
+		}
+		if overrides.file != "" {
+			filename = overrides.file
+		}
+	}
+
+	mutex := &sync.Mutex{}
 	db := &DBCache[T, EncoderT, DecoderT]{
+		commits:    committer{done: sync.NewCond(mutex)},
+		projected:  projectedKeys[T](options.projection),
+		options:    options,
 		cache:      filename,
+		env:        overrides,
 		data:       make(map[string]T),
 		lifetimes:  make(map[string]time.Time),
-		mutex:      &sync.Mutex{},
+		mutex:      mutex,
+		expiries:   newTimers(mutex),
+		schedules:  newTimers(mutex),
+		stats:      &stats{},
+		syncer:     syncer{running: &sync.WaitGroup{}},
 		newEncoder: encoder,
 		newDecoder: decoder,
 	}
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		if err := db.save(); err != nil {
+	if _, err := db.fsys.Stat(filename); errors.Is(err, fs.ErrNotExist) {
+		if err := db.flush(); err != nil {
 			return nil, err
 		}
 	}
-	return db, db.load()
+	if err := db.reload(); err != nil {
+		return db, err
+	}
+	db.applyEnv(overrides)
+	if db.seed != "" {
+		return db, db.applySeed()
+	}
+	return db, nil
 }
 
-func From[T any](filename string) (*DBCache[T, *json.Encoder, *json.Decoder], error) {
-	return Fromf[T](filename, json.NewEncoder, json.NewDecoder)
+func From[T any](filename string, opts ...Option) (*DBCache[T, *json.Encoder, *json.Decoder], error) {
+	return Fromf[T](filename, json.NewEncoder, json.NewDecoder, opts...)
 }
 
 type DBCache[T any, EncoderT Encoder, DecoderT Decoder] struct {
-	cache      string
-	data       map[string]T
-	lifetimes  map[string]time.Time
-	timeout    time.Duration
-	mutex      *sync.Mutex
-	lastSync   time.Time
-	newEncoder NewEncoder[EncoderT]
-	newDecoder NewDecoder[DecoderT]
+	options
+	cache        string
+	env          envOverrides
+	data         map[string]T
+	raw          map[string]json.RawMessage
+	projected    []string
+	normalize    func(key string) string
+	equal        func(a, b T) bool
+	slow         atomic.Int64
+	lifetimes    map[string]time.Time
+	timeout      time.Duration
+	mutex        *sync.Mutex
+	expiries     *timers
+	schedules    *timers
+	lastSync     time.Time
+	consistency  Consistency
+	syncer       syncer
+	commits      committer
+	backpressure backpressure
+	newEncoder   NewEncoder[EncoderT]
+	newDecoder   NewDecoder[DecoderT]
+	guard        Guard
+	panics       PanicPolicy
+	onError      ErrorHandler
+	loader       Loader[T]
+	resolver     Resolver[T]
+	quotas       map[string]Quota
+	counters     *usageCounters
+	geo          *geoIndex
+	stats        *stats
+	access       *sketch
 }
 
 func (db *DBCache[T, EncoderT, DecoderT]) Get(key string) (result T, err error) {
+	defer db.traceSlow("get", key)()
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	if err = db.load(); err != nil {
+	key = db.normalized(key)
+	if err = db.check(OpGet, key); err != nil {
+		return
+	}
+	if err = db.loadKey(key); err != nil {
 		return
 	}
-	return db.data[key], nil
+	result, ok := db.data[key]
+	db.stats.lookup(ok)
+	if db.access != nil {
+		db.access.add(key)
+	}
+	return result, nil
 }
 
 func (db *DBCache[T, EncoderT, DecoderT]) TryGet(key string) (result T, ok bool, err error) {
+	defer db.traceSlow("get", key)()
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	if err = db.load(); err != nil {
+	return db.tryGet(key)
+}
+
+// tryGet has to be called with the lock held.
+func (db *DBCache[T, EncoderT, DecoderT]) tryGet(key string) (result T, ok bool, err error) {
+	key = db.normalized(key)
+	if err = db.check(OpGet, key); err != nil {
+		return
+	}
+	if err = db.loadKey(key); err != nil {
 		return
 	}
 	result, ok = db.data[key]
+	db.stats.lookup(ok)
+	if db.access != nil {
+		db.access.add(key)
+	}
 	return
 }
 
 func (db *DBCache[T, EncoderT, DecoderT]) Add(key string, value T) error {
+	defer db.traceSlow("add", key)()
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	if err := db.load(); err != nil {
+	return db.add(key, value)
+}
+
+// add has to be called with the lock held.
+func (db *DBCache[T, EncoderT, DecoderT]) add(key string, value T) error {
+	key = db.normalized(key)
+	if err := db.check(OpAdd, key); err != nil {
+		return err
+	}
+	if err := db.throttle(); err != nil {
+		return err
+	}
+	if err := db.loadForWrite(); err != nil {
+		return err
+	}
+	if err := db.loadQuotas(); err != nil {
+		return err
+	}
+	if err := checkQuotas(db.quotas, db.quotaUsage(), key, value, db.size); err != nil {
 		return err
 	}
 
-	db.data[key] = value
-	db.lifetimes[key] = time.Now()
-	db.scheduleDel(key)
+	db.set(key, value, time.Now())
 
 	return db.save()
 }
 
+// GetOrSet returns the value key holds, or stores and saves value when it holds none.
+func (db *DBCache[T, EncoderT, DecoderT]) GetOrSet(key string, value T) (actual T, loaded bool, err error) {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err = db.check(OpGet, key); err != nil {
+		return
+	}
+	if err = db.loadKey(key); err != nil {
+		return
+	}
+	if current, ok := db.data[key]; ok {
+		return current, true, nil
+	}
+	if err = db.check(OpAdd, key); err != nil {
+		return
+	}
+	if err = db.throttle(); err != nil {
+		return
+	}
+	// throttle may have released the lock, another writer could have stored key meanwhile.
+	if current, ok := db.data[key]; ok {
+		return current, true, nil
+	}
+	if err = db.loadQuotas(); err != nil {
+		return
+	}
+	if err = checkQuotas(db.quotas, db.quotaUsage(), key, value, db.size); err != nil {
+		return
+	}
+
+	db.set(key, value, time.Now())
+	return value, false, db.save()
+}
+
 func (db *DBCache[T, EncoderT, DecoderT]) Del(key string) error {
+	defer db.traceSlow("del", key)()
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
+	key = db.normalized(key)
+	if err := db.check(OpDel, key); err != nil {
+		return err
+	}
+	if err := db.throttle(); err != nil {
+		return err
+	}
+	return db.del(key)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) del(key string) error {
 	if db.timeout == 0 || time.Since(db.lifetimes[key]) >= db.timeout {
-		delete(db.data, key)
-		delete(db.lifetimes, key)
+		db.unset(key)
 
 		if err := db.save(); err != nil {
-			slog.Error("nanodb-cache", "del", key, "err", err)
+			db.report("del", key, err)
 		}
 	}
 
 	return db.save()
 }
 
+// Seq2 iterates a copy taken under the lock, so a slow consumer does not block other calls
+// and may even write to the DB, changes made while iterating are not seen.
 func (db *DBCache[T, EncoderT, DecoderT]) Seq2() iter.Seq2[string, T] {
 	return func(yield func(string, T) bool) {
-		db.mutex.Lock()
-		defer db.mutex.Unlock()
-
-		_ = db.load()
-		for key, value := range db.data {
+		for key, value := range db.snapshotVisible() {
 			if !yield(key, value) {
 				return
 			}
@@ -122,6 +263,22 @@ func (db *DBCache[T, EncoderT, DecoderT]) Seq2() iter.Seq2[string, T] {
 	}
 }
 
+func (db *DBCache[T, EncoderT, DecoderT]) snapshotVisible() map[string]T {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		db.report("seq2", "", err)
+	}
+	visible := make(map[string]T, len(db.data))
+	for key, value := range db.data {
+		if db.check(OpGet, key) == nil {
+			visible[key] = value
+		}
+	}
+	return visible
+}
+
 func (db *DBCache[T, EncoderT, DecoderT]) Len() (int, error) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
@@ -142,17 +299,28 @@ func (db *DBCache[T, EncoderT, DecoderT]) KeysSnapshot() ([]string, error) {
 	}
 	keys := make([]string, 0, len(db.data))
 	for key := range db.data {
-		keys = append(keys, key)
+		if db.check(OpGet, key) == nil {
+			keys = append(keys, key)
+		}
 	}
 	return keys, nil
 }
 
+// Timeout sets how long entries live, a timeout set by WithEnv takes precedence.
 func (db *DBCache[T, EncoderT, DecoderT]) Timeout(timeout time.Duration) *DBCache[T, EncoderT, DecoderT] {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
+	if db.env.hasTimeout {
+		timeout = db.env.timeout
+	}
 	db.timeout = timeout
-	for key := range db.data {
+	if timeout == 0 {
+		for key := range db.lifetimes {
+			db.expiries.cancel(key)
+		}
+	}
+	for key := range db.keys() {
 		db.lifetimes[key] = time.Now()
 		db.scheduleDel(key)
 	}
@@ -160,28 +328,138 @@ func (db *DBCache[T, EncoderT, DecoderT]) Timeout(timeout time.Duration) *DBCach
 	return db
 }
 
+// Guard installs a permission check consulted before every keyed operation,
+// its error is returned to the caller as is.
+func (db *DBCache[T, EncoderT, DecoderT]) Guard(guard Guard) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.guard = guard
+	return db
+}
+
+// Quota limits keys starting with prefix, Add returns a *QuotaError when exceeded.
+// Bytes are measured with the cache encoder.
+func (db *DBCache[T, EncoderT, DecoderT]) Quota(prefix string, quota Quota) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	prefix = db.normalized(prefix)
+	if db.quotas == nil {
+		db.quotas = make(map[string]Quota)
+	}
+	db.quotas[prefix] = quota
+	db.counters = nil
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Usage(prefix string) (Usage, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	prefix = db.normalized(prefix)
+	if err := db.load(); err != nil {
+		return Usage{}, err
+	}
+	if _, ok := db.quotas[prefix]; ok {
+		if err := db.loadQuotas(); err != nil {
+			return Usage{}, err
+		}
+		return db.quotaUsage().usage[prefix], nil
+	}
+	return usageOf(db.data, prefix, db.size), nil
+}
+
+// quotaUsage has to be called with the lock held, the counters are built on first use
+// and dropped whenever the file is reloaded.
+func (db *DBCache[T, EncoderT, DecoderT]) quotaUsage() *usageCounters {
+	if db.counters == nil {
+		db.counters = countUsage(db.quotas, db.data, db.size)
+	}
+	return db.counters
+}
+
+// set has to be called with the lock held, every write of a key goes through it so the raw
+// entry, the indexes and the expiration follow the value.
+func (db *DBCache[T, EncoderT, DecoderT]) set(key string, value T, lifetime time.Time) {
+	db.forget(key)
+	db.data[key] = value
+	db.lifetimes[key] = lifetime
+	db.reindex(key)
+	db.scheduleDel(key)
+}
+
+// unset has to be called with the lock held, every deletion of a key goes through it.
+func (db *DBCache[T, EncoderT, DecoderT]) unset(key string) {
+	db.forget(key)
+	delete(db.data, key)
+	delete(db.lifetimes, key)
+	db.reindex(key)
+	db.expiries.cancel(key)
+}
+
+// reindex has to be called with the lock held after key was written, deleted or decoded, it keeps
+// the quota counters and the geohash index current.
+func (db *DBCache[T, EncoderT, DecoderT]) reindex(key string) {
+	value, ok := db.data[key]
+	if db.counters != nil {
+		track(db.counters, db.quotas, key, value, ok, db.size)
+	}
+	if db.geo != nil {
+		db.geo.update(key, value, ok)
+	}
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) size(value T) int {
+	counter := countingWriter(0)
+	if err := db.newEncoder(&counter).Encode(value); err != nil {
+		return 0
+	}
+	return int(counter)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) check(op Op, key string) (err error) {
+	if op != OpGet && db.projected != nil {
+		return ErrProjected
+	}
+	if db.guard == nil {
+		return nil
+	}
+	defer recoverPanic(db.panics, &err)
+	return db.guard(op, key)
+}
+
 func (db *DBCache[T, EncoderT, DecoderT]) scheduleDel(key string) {
 	if db.timeout == 0 {
 		return
 	}
 
-	time.AfterFunc(db.timeout, func() {
-		db.Del(key)
+	db.expiries.schedule(key, db.timeout-time.Since(db.lifetimes[key]), func() {
+		if err := db.del(key); err != nil {
+			db.report("expire", key, err)
+		}
 	})
 }
 
-func (db *DBCache[T, EncoderT, DecoderT]) load() error {
-	stat, err := os.Stat(db.cache)
+func (db *DBCache[T, EncoderT, DecoderT]) reload() (err error) {
+	defer recoverPanic(db.panics, &err)
+
+	stat, err := db.fsys.Stat(db.cache)
 	if err != nil {
 		return err
 	}
-	if !stat.ModTime().After(db.lastSync) {
+	// A zero modification time, as in embed.FS, never advances, so such files are always reloaded.
+	if !stat.ModTime().IsZero() && !stat.ModTime().After(db.lastSync) {
 		return nil
 	}
 
 	db.lastSync = stat.ModTime()
-	cache, err := os.Open(db.cache)
-	if err != nil && !os.IsNotExist(err) {
+	db.counters, db.geo = nil, nil
+	if db.hmacKey != nil {
+		return db.loadSigned()
+	}
+	cache, err := db.fsys.Open(db.cache)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
 	defer func() {
@@ -190,19 +468,75 @@ func (db *DBCache[T, EncoderT, DecoderT]) load() error {
 		}
 	}()
 
-	return db.newDecoder(cache).Decode(&db.data)
+	if db.resolver == nil && db.raws() {
+		return db.decodeRaw(cache)
+	}
+	if db.resolver == nil {
+		return db.newDecoder(cache).Decode(&db.data)
+	}
+	theirs := make(map[string]T)
+	if err := db.newDecoder(cache).Decode(&theirs); err != nil {
+		return err
+	}
+	db.merge(theirs, stat.ModTime())
+	return nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) merge(theirs map[string]T, modified time.Time) {
+	for key, value := range theirs {
+		db.data[key] = resolve(db.resolver, db.data, db.lifetimes, key, Versioned[T]{Value: value, Modified: modified})
+	}
 }
 
-func (db *DBCache[T, EncoderT, DecoderT]) save() error {
-	cache, err := os.OpenFile(db.cache, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil && !os.IsNotExist(err) {
+func (db *DBCache[T, EncoderT, DecoderT]) flush() error {
+	payload, err := db.encode()
+	if err != nil {
 		return err
 	}
-	defer func() {
-		if e := cache.Close(); err == nil && e != nil {
-			err = e
-		}
-	}()
+	if err := writeFile(db.fsys, db.cache, payload); err != nil {
+		return err
+	}
+	db.stats.lastSave = time.Now()
+	return nil
+}
 
-	return db.newEncoder(cache).Encode(db.data)
+// encode has to be called with the lock held, the payload is signed when WithHMAC is set.
+func (db *DBCache[T, EncoderT, DecoderT]) encode() (payload []byte, err error) {
+	defer recoverPanic(db.panics, &err)
+
+	buffer := &bytes.Buffer{}
+	if err := db.newEncoder(buffer).Encode(db.encoded()); err != nil {
+		return nil, err
+	}
+	if db.hmacKey != nil {
+		return sign(db.hmacKey, buffer.Bytes()), nil
+	}
+	return buffer.Bytes(), nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) loadSigned() error {
+	signed, err := fs.ReadFile(db.fsys, db.cache)
+	if err != nil {
+		return err
+	}
+	payload, err := verify(db.hmacKey, signed)
+	if err != nil {
+		db.lastSync = time.Time{}
+		return err
+	}
+
+	if db.resolver == nil && db.raws() {
+		db.data, db.raw = make(map[string]T), nil
+		return db.decodeRaw(bytes.NewReader(payload))
+	}
+	data := make(map[string]T)
+	if err := db.newDecoder(bytes.NewReader(payload)).Decode(&data); err != nil {
+		return err
+	}
+	if db.resolver == nil {
+		db.data = data
+		return nil
+	}
+	db.merge(data, db.lastSync)
+	return nil
 }
@@ -0,0 +1,236 @@
+package nanodb
+
+import (
+	"reflect"
+	"time"
+)
+
+// Equal sets how CompareAndSwap and CompareAndDelete compare values, reflect.DeepEqual by default.
+func (db *DB[T]) Equal(equal func(a, b T) bool) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.equal = equal
+	return db
+}
+
+// Equal sets how CompareAndSwap and CompareAndDelete compare values, reflect.DeepEqual by default.
+func (db *DBCache[T, EncoderT, DecoderT]) Equal(equal func(a, b T) bool) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.equal = equal
+	return db
+}
+
+// Swap stores value and returns the value key held before.
+// A refused write is reported as "add" and returns the zero value.
+func (db *DB[T]) Swap(key string, value T) (previous T, existed bool) {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) || !db.allowed(OpAdd, key) {
+		return previous, false
+	}
+	if err := db.admit(key, value); err != nil {
+		db.report("add", key, err)
+		return previous, false
+	}
+	previous, existed = db.lookup(key)
+	db.set(key, value, time.Now())
+	return previous, existed
+}
+
+// Swap stores and saves value and returns the value key held before.
+func (db *DBCache[T, EncoderT, DecoderT]) Swap(key string, value T) (previous T, existed bool, err error) {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err = db.check(OpGet, key); err != nil {
+		return
+	}
+	if err = db.check(OpAdd, key); err != nil {
+		return
+	}
+	if err = db.throttle(); err != nil {
+		return
+	}
+	if err = db.loadKey(key); err != nil {
+		return
+	}
+	if err = db.loadQuotas(); err != nil {
+		return
+	}
+	if err = checkQuotas(db.quotas, db.quotaUsage(), key, value, db.size); err != nil {
+		return
+	}
+
+	previous, existed = db.data[key]
+	db.set(key, value, time.Now())
+	return previous, existed, db.save()
+}
+
+// Pop deletes key and returns the value it held, so concurrent callers never both get it.
+// A refused delete is reported as "del" and returns the zero value.
+func (db *DB[T]) Pop(key string) (value T, ok bool) {
+	defer db.traceSlow("del", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) || !db.allowed(OpDel, key) {
+		return value, false
+	}
+	current, ok := db.lookup(key)
+	if !ok {
+		return value, false
+	}
+	if err := db.checkRefs(batchOp[T]{key: key, del: true}); err != nil {
+		db.report("del", key, err)
+		return value, false
+	}
+	db.unset(key)
+	return current, true
+}
+
+// Pop deletes key with a single save and returns the value it held, so concurrent callers never both get it.
+func (db *DBCache[T, EncoderT, DecoderT]) Pop(key string) (value T, ok bool, err error) {
+	defer db.traceSlow("del", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err = db.check(OpGet, key); err != nil {
+		return
+	}
+	if err = db.check(OpDel, key); err != nil {
+		return
+	}
+	if err = db.throttle(); err != nil {
+		return
+	}
+	if err = db.loadKey(key); err != nil {
+		return
+	}
+	if value, ok = db.data[key]; !ok {
+		return
+	}
+
+	db.unset(key)
+	return value, true, db.save()
+}
+
+// CompareAndSwap stores new only while key holds a value equal to old, and reports whether it did.
+// A refused write is reported as "add".
+func (db *DB[T]) CompareAndSwap(key string, old, new T) bool {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) || !db.allowed(OpAdd, key) {
+		return false
+	}
+	if current, ok := db.lookup(key); !ok || !equals(db.equal, current, old) {
+		return false
+	}
+	if err := db.admit(key, new); err != nil {
+		db.report("add", key, err)
+		return false
+	}
+	db.set(key, new, time.Now())
+	return true
+}
+
+// CompareAndSwap stores and saves new only while key holds a value equal to old, and reports whether it did.
+func (db *DBCache[T, EncoderT, DecoderT]) CompareAndSwap(key string, old, new T) (bool, error) {
+	defer db.traceSlow("add", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpGet, key); err != nil {
+		return false, err
+	}
+	if err := db.check(OpAdd, key); err != nil {
+		return false, err
+	}
+	if err := db.throttle(); err != nil {
+		return false, err
+	}
+	if err := db.loadKey(key); err != nil {
+		return false, err
+	}
+	if current, ok := db.data[key]; !ok || !equals(db.equal, current, old) {
+		return false, nil
+	}
+	if err := db.loadQuotas(); err != nil {
+		return false, err
+	}
+	if err := checkQuotas(db.quotas, db.quotaUsage(), key, new, db.size); err != nil {
+		return false, err
+	}
+
+	db.set(key, new, time.Now())
+	return true, db.save()
+}
+
+// CompareAndDelete deletes key only while it holds a value equal to expected, and reports whether it did.
+// A refused delete is reported as "del".
+func (db *DB[T]) CompareAndDelete(key string, expected T) bool {
+	defer db.traceSlow("del", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) || !db.allowed(OpDel, key) {
+		return false
+	}
+	if current, ok := db.lookup(key); !ok || !equals(db.equal, current, expected) {
+		return false
+	}
+	if err := db.checkRefs(batchOp[T]{key: key, del: true}); err != nil {
+		db.report("del", key, err)
+		return false
+	}
+	db.unset(key)
+	return true
+}
+
+// CompareAndDelete deletes key and saves only while it holds a value equal to expected, and reports whether it did.
+func (db *DBCache[T, EncoderT, DecoderT]) CompareAndDelete(key string, expected T) (bool, error) {
+	defer db.traceSlow("del", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpGet, key); err != nil {
+		return false, err
+	}
+	if err := db.check(OpDel, key); err != nil {
+		return false, err
+	}
+	if err := db.throttle(); err != nil {
+		return false, err
+	}
+	if err := db.loadKey(key); err != nil {
+		return false, err
+	}
+	if current, ok := db.data[key]; !ok || !equals(db.equal, current, expected) {
+		return false, nil
+	}
+
+	db.unset(key)
+	return true, db.save()
+}
+
+func equals[T any](equal func(a, b T) bool, a, b T) bool {
+	if equal == nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return equal(a, b)
+}
@@ -0,0 +1,103 @@
+package nanodb
+
+import (
+	"log/slog"
+	"time"
+)
+
+type EntryMeta struct {
+	Modified time.Time
+	// Expires is zero when the store has no timeout.
+	Expires time.Time
+	// Size is measured with encoding/json for DB and with the cache encoder for DBCache.
+	Size int
+}
+
+func (meta EntryMeta) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.Time("modified", meta.Modified), slog.Int("size", meta.Size)}
+	if !meta.Expires.IsZero() {
+		attrs = append(attrs, slog.Time("expires", meta.Expires))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// CleanupPolicy deletes every interval the entries keep returns false for, independently of the timeout.
+// A zero interval or a nil keep removes the policy.
+func (db *DB[T]) CleanupPolicy(interval time.Duration, keep func(key string, meta EntryMeta, value T) bool) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.schedules.cancel("cleanup")
+	if interval <= 0 || keep == nil {
+		return db
+	}
+	db.schedules.every("cleanup", interval, func() {
+		for key, value := range db.data {
+			meta := EntryMeta{Modified: db.lifetimes[key], Expires: expiresAt(db.ttl(key), db.lifetimes[key]), Size: jsonSize(value)}
+			if ok, err := keepEntry(db.panics, keep, key, meta, value); err != nil {
+				db.report("cleanup", key, err)
+			} else if !ok {
+				db.evict(key)
+			}
+		}
+	})
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) CleanupPolicy(
+	interval time.Duration,
+	keep func(key string, meta EntryMeta, value T) bool,
+) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.schedules.cancel("cleanup")
+	if interval <= 0 || keep == nil {
+		return db
+	}
+	db.schedules.every("cleanup", interval, func() {
+		if err := db.loadForWrite(); err != nil {
+			db.report("cleanup", "", err)
+			return
+		}
+		if err := db.materialize(); err != nil {
+			db.report("cleanup", "", err)
+			return
+		}
+		deleted := false
+		for key, value := range db.data {
+			meta := EntryMeta{Modified: db.lifetimes[key], Expires: expiresAt(db.timeout, db.lifetimes[key]), Size: db.size(value)}
+			if ok, err := keepEntry(db.panics, keep, key, meta, value); err != nil {
+				db.report("cleanup", key, err)
+			} else if !ok {
+				db.unset(key)
+				deleted = true
+			}
+		}
+		if !deleted {
+			return
+		}
+		if err := db.save(); err != nil {
+			db.report("cleanup", "", err)
+		}
+	})
+	return db
+}
+
+func keepEntry[T any](
+	policy PanicPolicy,
+	keep func(key string, meta EntryMeta, value T) bool,
+	key string,
+	meta EntryMeta,
+	value T,
+) (ok bool, err error) {
+	defer recoverPanic(policy, &err)
+	return keep(key, meta, value), nil
+}
+
+func expiresAt(timeout time.Duration, lifetime time.Time) time.Time {
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return lifetime.Add(timeout)
+}
@@ -0,0 +1,57 @@
+package nanodb
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Clear deletes every key the guard allows deleting, cancelling their expirations, refreshes and
+// AddRecurring schedules, observers see a deletion per key. A clear breaking a RequireRef reference deletes nothing and is reported.
+func (db *DB[T]) Clear() *DB[T] {
+	defer db.traceSlow("clear", "")()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	ops := make([]batchOp[T], 0, len(db.data))
+	for key := range db.data {
+		if db.allowed(OpDel, key) {
+			ops = append(ops, batchOp[T]{key: key, del: true})
+		}
+	}
+	if err := db.checkRefs(ops...); err != nil {
+		db.report("clear", "", err)
+		return db
+	}
+	for _, op := range ops {
+		db.unset(op.key)
+	}
+	for timer := range maps.Clone(db.schedules.byKey) {
+		if key, ok := strings.CutPrefix(timer, "recurring:"); ok && db.allowed(OpDel, key) {
+			db.schedules.cancel(timer)
+		}
+	}
+	return db
+}
+
+// Clear deletes every key the guard allows deleting, cancelling their expirations, and saves once.
+// Clearing everything leaves an empty store in the file.
+func (db *DBCache[T, EncoderT, DecoderT]) Clear() error {
+	defer db.traceSlow("clear", "")()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.throttle(); err != nil {
+		return err
+	}
+	if err := db.loadForWrite(); err != nil {
+		return err
+	}
+	for _, key := range slices.Collect(db.keys()) {
+		if db.check(OpDel, key) != nil {
+			continue
+		}
+		db.unset(key)
+	}
+	return db.save()
+}
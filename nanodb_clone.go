@@ -0,0 +1,40 @@
+package nanodb
+
+import "time"
+
+// Snapshot copies the entries visible to the guard under the read lock, for a consistent view
+// while writes continue. Values are copied as is, pointers and maps inside them stay shared.
+func (db *DB[T]) Snapshot() map[string]T {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, _ := db.visible()
+	return data
+}
+
+// Clone is Snapshot as a new DB, entries keep their insertion times.
+// It gets neither the timeout nor the guard, callbacks or indexes of db, besides those New adds.
+func (db *DB[T]) Clone() *DB[T] {
+	db.mutex.RLock()
+	data, lifetimes := db.visible()
+	db.mutex.RUnlock()
+
+	clone := New[T]()
+	for key, value := range data {
+		clone.set(key, value, lifetimes[key])
+	}
+	return clone
+}
+
+// visible has to be called with the lock held.
+func (db *DB[T]) visible() (map[string]T, map[string]time.Time) {
+	data := make(map[string]T, len(db.data))
+	lifetimes := make(map[string]time.Time, len(db.lifetimes))
+	for key, value := range db.data {
+		if db.allowed(OpGet, key) {
+			data[key] = value
+			lifetimes[key] = db.lifetimes[key]
+		}
+	}
+	return data, lifetimes
+}
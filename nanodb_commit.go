@@ -0,0 +1,62 @@
+package nanodb
+
+import (
+	"sync"
+	"time"
+)
+
+// committer merges the saves requested while the file is being written into a single next save.
+type committer struct {
+	done      *sync.Cond
+	requested uint64
+	saved     uint64
+	saving    bool
+	err       error
+}
+
+// pending reports whether memory holds changes the file does not have yet.
+func (c *committer) pending() bool {
+	return c.saving || c.requested > c.saved
+}
+
+// groupCommit has to be called with the lock held, it releases the lock while the file is written
+// so writers arriving meanwhile only change memory and wait for the next save to carry their changes.
+// The error is the one of the save that included the caller's changes.
+func (db *DBCache[T, EncoderT, DecoderT]) groupCommit() error {
+	db.commits.requested++
+	ticket := db.commits.requested
+	for db.commits.saved < ticket {
+		if db.commits.saving {
+			db.commits.done.Wait()
+			continue
+		}
+
+		db.commits.saving = true
+		target := db.commits.requested
+		payload, err := db.encode()
+		if err == nil {
+			db.mutex.Unlock()
+			err = writeFile(db.fsys, db.cache, payload)
+			db.mutex.Lock()
+		}
+		if err == nil {
+			db.stats.lastSave = time.Now()
+			if db.consistency == ReadOwnWrites {
+				if stat, err := db.fsys.Stat(db.cache); err == nil {
+					db.lastSync = stat.ModTime()
+				}
+			}
+		}
+		db.commits.saving = false
+		db.commits.saved, db.commits.err = target, err
+		db.commits.done.Broadcast()
+	}
+	return db.commits.err
+}
+
+// waitCommits has to be called with the lock held, it returns once no save is writing the file.
+func (db *DBCache[T, EncoderT, DecoderT]) waitCommits() {
+	for db.commits.saving {
+		db.commits.done.Wait()
+	}
+}
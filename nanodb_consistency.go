@@ -0,0 +1,155 @@
+package nanodb
+
+import (
+	"sync"
+	"time"
+)
+
+type Consistency int
+
+const (
+	// Strict reloads the file before every operation and saves after every write.
+	Strict Consistency = iota
+	// Relaxed treats memory as authoritative: the file is never reloaded
+	// and writes are saved in the background every sync interval.
+	Relaxed
+	// ReadOwnWrites saves after every write but reloads only before reads,
+	// and only when another process changed the file.
+	ReadOwnWrites
+)
+
+const defaultSyncInterval = time.Second
+
+type syncer struct {
+	interval time.Duration
+	dirty    bool
+	unsaved  int
+	stop     chan struct{}
+	running  *sync.WaitGroup
+}
+
+// Consistency sets the consistency mode, a mode set by WithEnv takes precedence.
+func (db *DBCache[T, EncoderT, DecoderT]) Consistency(mode Consistency) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.env.hasMode {
+		mode = db.env.consistency
+	}
+	db.consistency = mode
+	return db
+}
+
+// SyncInterval sets how often Relaxed mode saves pending writes, an interval set by WithEnv takes precedence.
+func (db *DBCache[T, EncoderT, DecoderT]) SyncInterval(interval time.Duration) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.env.syncInterval > 0 {
+		interval = db.env.syncInterval
+	}
+	db.syncer.interval = interval
+	return db
+}
+
+// load skips the reload while a save is pending, memory is ahead of the file until it finishes.
+// Entries kept raw by WithLazyDecoding are all decoded.
+func (db *DBCache[T, EncoderT, DecoderT]) load() error {
+	if err := db.loadKey(""); err != nil {
+		return err
+	}
+	return db.materialize()
+}
+
+// loadKey is load decoding only key.
+func (db *DBCache[T, EncoderT, DecoderT]) loadKey(key string) error {
+	if db.consistency != Relaxed && !db.commits.pending() {
+		if err := db.reload(); err != nil {
+			return err
+		}
+	}
+	return db.decodeKey(key)
+}
+
+// loadForWrite leaves the raw entries raw, writers forget or decode the keys they touch.
+func (db *DBCache[T, EncoderT, DecoderT]) loadForWrite() error {
+	if db.consistency != Strict || db.commits.pending() {
+		return nil
+	}
+	return db.reload()
+}
+
+// loadQuotas decodes every entry when quotas have to be measured against them.
+func (db *DBCache[T, EncoderT, DecoderT]) loadQuotas() error {
+	if len(db.quotas) == 0 {
+		return nil
+	}
+	return db.materialize()
+}
+
+// save may release the lock while the file is written, see groupCommit.
+func (db *DBCache[T, EncoderT, DecoderT]) save() error {
+	if db.projected != nil {
+		return ErrProjected
+	}
+	if db.consistency == Relaxed {
+		db.syncer.dirty = true
+		db.syncer.unsaved++
+		db.startSyncer()
+		return nil
+	}
+	return db.groupCommit()
+}
+
+// startSyncer has to be called with the lock held.
+func (db *DBCache[T, EncoderT, DecoderT]) startSyncer() {
+	if db.syncer.stop != nil || db.expiries.closed {
+		return
+	}
+	if db.syncer.interval <= 0 {
+		db.syncer.interval = defaultSyncInterval
+	}
+
+	stop := make(chan struct{})
+	db.syncer.stop = stop
+	db.syncer.running.Add(1)
+	go func() {
+		defer db.syncer.running.Done()
+
+		ticker := time.NewTicker(db.syncer.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				db.mutex.Lock()
+				if err := db.sync(); err != nil {
+					db.report("sync", "", err)
+				}
+				db.mutex.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// sync has to be called with the lock held.
+func (db *DBCache[T, EncoderT, DecoderT]) sync() error {
+	if !db.syncer.dirty {
+		return nil
+	}
+	if err := db.flush(); err != nil {
+		return err
+	}
+	db.syncer.dirty, db.syncer.unsaved = false, 0
+	return nil
+}
+
+// stopSyncer has to be called with the lock held, it saves pending writes.
+func (db *DBCache[T, EncoderT, DecoderT]) stopSyncer() error {
+	if db.syncer.stop != nil {
+		close(db.syncer.stop)
+		db.syncer.stop = nil
+	}
+	return db.sync()
+}
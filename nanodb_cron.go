@@ -0,0 +1,179 @@
+package nanodb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSchedule = errors.New("nanodb: invalid schedule")
+
+// AddRecurring stores produce() under key right away and again at every time schedule names.
+// A schedule is a cron expression, "minute hour day-of-month month day-of-week" with *, lists,
+// ranges and steps like "*/15 9-17 * * 1-5", one of @hourly, @daily, @weekly, @monthly and @yearly,
+// or "@every 90s". Times are local. produce runs on the worker pool, adding a recurring key again
+// replaces its schedule.
+func (db *DB[T]) AddRecurring(key string, schedule string, produce func() T) error {
+	next, err := parseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	db.recur(key, next, produce)
+	db.workerPool().submit(func() { db.Add(key, produce()) })
+	return nil
+}
+
+// StopRecurring cancels the schedule of key, the value last produced stays.
+func (db *DB[T]) StopRecurring(key string) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.schedules.cancel("recurring:" + db.normalized(key))
+	return db
+}
+
+// recur has to be called with the lock held.
+func (db *DB[T]) recur(key string, next func(after time.Time) time.Time, produce func() T) {
+	now := time.Now()
+	at := next(now)
+	if at.IsZero() {
+		return
+	}
+	pool := db.workerPool()
+	db.schedules.schedule("recurring:"+key, at.Sub(now), func() {
+		pool.submit(func() { db.Add(key, produce()) })
+		db.recur(key, next, produce)
+	})
+}
+
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron holds a bit per allowed value of every field.
+type cron struct {
+	minute, hour, day, month, weekday uint64
+	// anyDay and anyWeekday are set for "*", cron matches either day field when both are restricted.
+	anyDay, anyWeekday bool
+}
+
+// parseSchedule returns the func finding the first scheduled time after a given one, zero when there is none.
+func parseSchedule(schedule string) (func(after time.Time) time.Time, error) {
+	schedule = strings.TrimSpace(schedule)
+	if every, ok := strings.CutPrefix(schedule, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrSchedule, schedule)
+		}
+		return func(after time.Time) time.Time { return after.Add(interval) }, nil
+	}
+	if expanded, ok := scheduleMacros[schedule]; ok {
+		schedule = expanded
+	}
+
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q has %d fields, expected 5", ErrSchedule, schedule, len(fields))
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseField(field, bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrSchedule, schedule, err)
+		}
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1 // 7 is Sunday as well
+	}
+	c := cron{
+		minute: bits[0], hour: bits[1], day: bits[2], month: bits[3], weekday: bits[4],
+		anyDay: fields[2] == "*", anyWeekday: fields[4] == "*",
+	}
+	return c.next, nil
+}
+
+func parseField(field string, low, high int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		span, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(after); err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			span = before
+		}
+		from, to := low, high
+		if span != "*" {
+			start, end, isRange := strings.Cut(span, "-")
+			var err error
+			if from, err = strconv.Atoi(start); err != nil {
+				return 0, fmt.Errorf("bad value in %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(end); err != nil {
+					return 0, fmt.Errorf("bad range in %q", part)
+				}
+			} else if step != 1 {
+				to = high
+			}
+		}
+		if from < low || to > high || from > to {
+			return 0, fmt.Errorf("%q out of %d-%d", part, low, high)
+		}
+		for value := from; value <= to; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// next skips whole months, days and hours that do not match, giving up after five years.
+func (c cron) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	loc := t.Location()
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cron) matchesDay(t time.Time) bool {
+	day, weekday := c.day&(1<<t.Day()) != 0, c.weekday&(1<<t.Weekday()) != 0
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
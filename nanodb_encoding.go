@@ -0,0 +1,78 @@
+package nanodb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"maps"
+	"time"
+)
+
+type dbEncoded[T any] struct {
+	Data      map[string]T         `json:"data"`
+	Timeout   time.Duration        `json:"timeout,omitempty"`
+	Lifetimes map[string]time.Time `json:"lifetimes,omitempty"`
+}
+
+// MarshalJSON encodes the entries, lifetimes are only included when a timeout is set.
+func (db *DB[T]) MarshalJSON() ([]byte, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return json.Marshal(db.encoded())
+}
+
+// UnmarshalJSON replaces the contents of db, a zero DB is initialised first.
+func (db *DB[T]) UnmarshalJSON(data []byte) error {
+	decoded := dbEncoded[T]{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	db.replace(decoded)
+	return nil
+}
+
+func (db *DB[T]) GobEncode() ([]byte, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	encoded := &bytes.Buffer{}
+	if err := gob.NewEncoder(encoded).Encode(db.encoded()); err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+func (db *DB[T]) GobDecode(data []byte) error {
+	decoded := dbEncoded[T]{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+	db.replace(decoded)
+	return nil
+}
+
+func (db *DB[T]) encoded() dbEncoded[T] {
+	encoded := dbEncoded[T]{Data: db.data, Timeout: db.timeout}
+	if db.timeout != 0 {
+		encoded.Lifetimes = db.lifetimes
+	}
+	return encoded
+}
+
+func (db *DB[T]) replace(decoded dbEncoded[T]) {
+	if db.mutex == nil {
+		*db = *New[T]()
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for key := range maps.Clone(db.data) {
+		db.unset(key)
+	}
+	db.timeout = decoded.Timeout
+	for key, value := range decoded.Data {
+		db.set(key, value, lifetimeOr(decoded.Lifetimes, key))
+	}
+}
@@ -0,0 +1,81 @@
+package nanodb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// WithEnv lets environment variables override the cache settings:
+//
+//	<PREFIX>_FILE           cache file path
+//	<PREFIX>_TIMEOUT        entry timeout, e.g. 10m
+//	<PREFIX>_SYNC_INTERVAL  Relaxed mode sync interval, e.g. 5s
+//	<PREFIX>_CONSISTENCY    strict, relaxed or read-own-writes
+//
+// A variable that is set wins over the filename passed to From and over later calls
+// to Timeout, SyncInterval and Consistency, so deployments can tune a cache the code configures.
+func WithEnv(prefix string) Option {
+	return func(opts *options) {
+		opts.envPrefix = prefix
+	}
+}
+
+type envOverrides struct {
+	file         string
+	timeout      time.Duration
+	syncInterval time.Duration
+	consistency  Consistency
+	hasTimeout   bool
+	hasMode      bool
+}
+
+func readEnv(prefix string) (envOverrides, error) {
+	overrides := envOverrides{}
+	lookup := func(name string) (string, bool) {
+		return os.LookupEnv(prefix + "_" + name)
+	}
+
+	overrides.file, _ = lookup("FILE")
+	if value, ok := lookup("TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return overrides, fmt.Errorf("nanodb: %s_TIMEOUT: %w", prefix, err)
+		}
+		overrides.timeout, overrides.hasTimeout = timeout, true
+	}
+	if value, ok := lookup("SYNC_INTERVAL"); ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return overrides, fmt.Errorf("nanodb: %s_SYNC_INTERVAL: %w", prefix, err)
+		}
+		overrides.syncInterval = interval
+	}
+	if value, ok := lookup("CONSISTENCY"); ok {
+		switch strings.ToLower(value) {
+		case "strict":
+			overrides.consistency = Strict
+		case "relaxed":
+			overrides.consistency = Relaxed
+		case "read-own-writes":
+			overrides.consistency = ReadOwnWrites
+		default:
+			return overrides, fmt.Errorf("nanodb: %s_CONSISTENCY: unknown mode %q", prefix, value)
+		}
+		overrides.hasMode = true
+	}
+	return overrides, nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) applyEnv(overrides envOverrides) {
+	if overrides.hasMode {
+		db.Consistency(overrides.consistency)
+	}
+	if overrides.syncInterval > 0 {
+		db.SyncInterval(overrides.syncInterval)
+	}
+	if overrides.hasTimeout {
+		db.Timeout(overrides.timeout)
+	}
+}
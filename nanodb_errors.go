@@ -0,0 +1,45 @@
+package nanodb
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var ErrCallbackDropped = errors.New("nanodb: callback dropped, worker pool queue is full")
+
+// ErrorHandler receives errors from paths with no caller to return them to:
+// expiration saves, reloads during iteration, dropped or panicking callbacks.
+// It may be called with the lock held, so it must not call back into the same DB.
+type ErrorHandler func(op string, key string, err error)
+
+func (db *DB[T]) OnError(handler ErrorHandler) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.onError = handler
+	return db
+}
+
+func (db *DB[T]) report(op string, key string, err error) {
+	reportError(db.onError, "nanodb", op, key, err)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) OnError(handler ErrorHandler) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.onError = handler
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) report(op string, key string, err error) {
+	reportError(db.onError, "nanodb-cache", op, key, err)
+}
+
+func reportError(handler ErrorHandler, source string, op string, key string, err error) {
+	if handler == nil {
+		slog.Error(source, op, key, "err", err)
+		return
+	}
+	handler(op, key, err)
+}
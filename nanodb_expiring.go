@@ -0,0 +1,61 @@
+package nanodb
+
+import (
+	"iter"
+	"time"
+)
+
+// ExpiringWithin yields the entries that expire in less than d, nothing without a timeout.
+// Like Seq2 it iterates a copy taken under the lock, so the loop may renew them with Add.
+func (db *DB[T]) ExpiringWithin(d time.Duration) iter.Seq2[string, T] {
+	return func(yield func(string, T) bool) {
+		for key, value := range db.expiring(d) {
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+func (db *DB[T]) expiring(d time.Duration) map[string]T {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	expiring := make(map[string]T)
+	for key, value := range db.data {
+		if expiresWithin(db.ttl(key), db.lifetimes[key], d) && db.allowed(OpGet, key) {
+			expiring[key] = value
+		}
+	}
+	return expiring
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) ExpiringWithin(d time.Duration) iter.Seq2[string, T] {
+	return func(yield func(string, T) bool) {
+		for key, value := range db.expiring(d) {
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) expiring(d time.Duration) map[string]T {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		db.report("expiring", "", err)
+	}
+	expiring := make(map[string]T)
+	for key, value := range db.data {
+		if expiresWithin(db.timeout, db.lifetimes[key], d) && db.check(OpGet, key) == nil {
+			expiring[key] = value
+		}
+	}
+	return expiring
+}
+
+func expiresWithin(timeout time.Duration, lifetime time.Time, d time.Duration) bool {
+	return timeout != 0 && time.Until(lifetime.Add(timeout)) < d
+}
@@ -0,0 +1,30 @@
+package nanodb
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// String describes db without its keys or values, it never blocks on a busy DB.
+func (db *DB[T]) String() string {
+	if !db.mutex.TryRLock() {
+		return "nanodb.DB{busy}"
+	}
+	defer db.mutex.RUnlock()
+
+	return fmt.Sprintf("nanodb.DB{len: %d, timeout: %v}", len(db.data), db.timeout)
+}
+
+func (db *DB[T]) LogValue() slog.Value {
+	if !db.mutex.TryRLock() {
+		return slog.GroupValue(slog.Bool("busy", true))
+	}
+	defer db.mutex.RUnlock()
+
+	stats := db.stats.snapshot(len(db.data))
+	return slog.GroupValue(
+		slog.Int("len", stats.Len),
+		slog.Duration("timeout", db.timeout),
+		slog.Float64("hit_rate", stats.HitRate()),
+	)
+}
@@ -0,0 +1,123 @@
+package nanodb
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing/fstest"
+	"time"
+)
+
+// FS is the file access of DBCache, swap it with WithFS to inject faults or persist elsewhere.
+type FS interface {
+	fs.StatFS
+	// Create truncates or creates the file for writing.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// WithFS makes DBCache read and write its cache and seed files through fsys instead of the os package.
+func WithFS(fsys FS) Option {
+	return func(opts *options) {
+		opts.fsys = fsys
+	}
+}
+
+// OSFS is the default FS, names are passed to the os package as is.
+type OSFS struct{}
+
+func (OSFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (OSFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (OSFS) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+
+func writeFile(fsys FS, name string, data []byte) (err error) {
+	file, err := fsys.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := file.Close(); err == nil && e != nil {
+			err = e
+		}
+	}()
+
+	_, err = file.Write(data)
+	return err
+}
+
+// ReadOnlyFS serves the cache from fsys, e.g. an embed.FS snapshot, and rejects every write.
+// Pair it with Relaxed consistency so writes only live in memory.
+func ReadOnlyFS(fsys fs.FS) FS {
+	return readOnlyFS{fsys}
+}
+
+type readOnlyFS struct {
+	fs.FS
+}
+
+func (fsys readOnlyFS) Stat(name string) (fs.FileInfo, error) { return fs.Stat(fsys.FS, name) }
+
+func (readOnlyFS) Create(name string) (io.WriteCloser, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrPermission}
+}
+
+// MemFS keeps files in memory, names have to satisfy fs.ValidPath.
+type MemFS struct {
+	mutex sync.Mutex
+	files fstest.MapFS
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(fstest.MapFS)}
+}
+
+func (fsys *MemFS) Open(name string) (fs.File, error) {
+	return fsys.snapshot(name).Open(name)
+}
+
+func (fsys *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.snapshot(name).Stat(name)
+}
+
+func (fsys *MemFS) Create(name string) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.store(name, nil)
+	return &memFile{fsys: fsys, name: name}, nil
+}
+
+func (fsys *MemFS) snapshot(name string) fstest.MapFS {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	file, ok := fsys.files[name]
+	if !ok {
+		return fstest.MapFS{}
+	}
+	return fstest.MapFS{name: &fstest.MapFile{Data: file.Data, Mode: file.Mode, ModTime: file.ModTime}}
+}
+
+func (fsys *MemFS) store(name string, data []byte) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	fsys.files[name] = &fstest.MapFile{Data: data, Mode: 0666, ModTime: time.Now()}
+}
+
+type memFile struct {
+	fsys *MemFS
+	name string
+	data bytes.Buffer
+}
+
+func (file *memFile) Write(p []byte) (int, error) {
+	n, _ := file.data.Write(p)
+	file.fsys.store(file.name, bytes.Clone(file.data.Bytes()))
+	return n, nil
+}
+
+func (file *memFile) Close() error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package nanodb
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	geohashAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geohashPrecision = 9
+	earthRadius      = 6371008.8
+	metersPerDegree  = earthRadius * math.Pi / 180
+)
+
+// GeoPoint keeps the geohash next to the coordinates so Near can filter by prefix before measuring.
+type GeoPoint struct {
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Geohash string  `json:"geohash"`
+}
+
+func GeoAdd(db Store[GeoPoint], key string, lat, lon float64) error {
+	return db.update(key, func(GeoPoint, bool) (GeoPoint, Op) {
+		return GeoPoint{Lat: lat, Lon: lon, Geohash: Geohash(lat, lon, geohashPrecision)}, OpAdd
+	})
+}
+
+// Near returns keys within radius meters of the point, closest first. DB and DBCache look
+// the points up in a geohash index sorted by hash, other stores measure every point.
+func Near(db Store[GeoPoint], lat, lon, radius float64) []string {
+	precision := geohashPrecision
+	for precision > 1 && !geohashCovers(precision, lat, radius) {
+		precision--
+	}
+
+	type candidate struct {
+		key      string
+		distance float64
+	}
+	candidates := []candidate{}
+	visit := func(key string, point GeoPoint) {
+		if distance := GeoDistance(lat, lon, point.Lat, point.Lon); distance <= radius {
+			candidates = append(candidates, candidate{key, distance})
+		}
+	}
+
+	// Nine cells at least as large as the radius cover the circle, past the size of a
+	// precision 1 cell they no longer do and every point is measured.
+	scanner, indexed := db.(geoScanner)
+	if !indexed || !geohashCovers(precision, lat, radius) {
+		for key, point := range db.Seq2() {
+			visit(key, point)
+		}
+	} else {
+		scanner.geoScan(geohashCells(lat, lon, radius, precision), visit)
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Compare(a.distance, b.distance)
+	})
+
+	keys := make([]string, len(candidates))
+	for i, c := range candidates {
+		keys[i] = c.key
+	}
+	return keys
+}
+
+// GeoDistance is the haversine distance in meters.
+func GeoDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dlat, dlon := (lat2-lat1)*toRad, (lon2-lon1)*toRad
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(min(a, 1)))
+}
+
+func Geohash(lat, lon float64, precision int) string {
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	bit, ch, even := 0, 0, true
+	for len(hash) < precision {
+		value, bounds := lon, &lonRange
+		if !even {
+			value, bounds = lat, &latRange
+		}
+		mid := (bounds[0] + bounds[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			bounds[0] = mid
+		} else {
+			bounds[1] = mid
+		}
+
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+func geohashCovers(precision int, lat, radius float64) bool {
+	lonBits := (5*precision + 1) / 2
+	latBits := 5 * precision / 2
+	height := 180 / math.Exp2(float64(latBits)) * metersPerDegree
+	width := 360 / math.Exp2(float64(lonBits)) * metersPerDegree * math.Cos(lat*math.Pi/180)
+	return height >= radius && width >= radius
+}
+
+// geohashCells are the distinct cells of the point and its eight neighbours at radius.
+func geohashCells(lat, lon, radius float64, precision int) []string {
+	dlat := radius / metersPerDegree
+	dlon := radius / (metersPerDegree * max(math.Cos(lat*math.Pi/180), 1e-6))
+	cells := []string{}
+	for _, y := range []float64{-dlat, 0, dlat} {
+		for _, x := range []float64{-dlon, 0, dlon} {
+			cell := Geohash(max(min(lat+y, 90), -90), math.Remainder(lon+x, 360), precision)
+			if !slices.Contains(cells, cell) {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+// geoScanner is implemented by the stores keeping a geoIndex, geoScan visits the points
+// whose geohash starts with one of cells.
+type geoScanner interface {
+	geoScan(cells []string, visit func(key string, point GeoPoint))
+}
+
+// geoIndex keeps the keys sorted by geohash, so the points of a cell are one range of it.
+type geoIndex struct {
+	sorted []geoEntry
+	hashes map[string]string
+}
+
+type geoEntry struct {
+	hash string
+	key  string
+}
+
+func newGeoIndex[T any](data map[string]T) *geoIndex {
+	index := &geoIndex{hashes: make(map[string]string)}
+	for key, value := range data {
+		if point, ok := any(value).(GeoPoint); ok {
+			index.sorted = append(index.sorted, geoEntry{hash: point.Geohash, key: key})
+			index.hashes[key] = point.Geohash
+		}
+	}
+	slices.SortFunc(index.sorted, compareGeoEntries)
+	return index
+}
+
+// update moves key to the geohash of value, or drops it when exists is false.
+func (index *geoIndex) update(key string, value any, exists bool) {
+	if hash, ok := index.hashes[key]; ok {
+		if i, found := slices.BinarySearchFunc(index.sorted, geoEntry{hash: hash, key: key}, compareGeoEntries); found {
+			index.sorted = slices.Delete(index.sorted, i, i+1)
+		}
+		delete(index.hashes, key)
+	}
+	point, ok := value.(GeoPoint)
+	if !exists || !ok {
+		return
+	}
+	entry := geoEntry{hash: point.Geohash, key: key}
+	i, _ := slices.BinarySearchFunc(index.sorted, entry, compareGeoEntries)
+	index.sorted = slices.Insert(index.sorted, i, entry)
+	index.hashes[key] = point.Geohash
+}
+
+// scan visits the keys whose geohash starts with cell.
+func (index *geoIndex) scan(cell string, visit func(key string)) {
+	i, _ := slices.BinarySearchFunc(index.sorted, geoEntry{hash: cell}, compareGeoEntries)
+	for ; i < len(index.sorted) && strings.HasPrefix(index.sorted[i].hash, cell); i++ {
+		visit(index.sorted[i].key)
+	}
+}
+
+func compareGeoEntries(a, b geoEntry) int {
+	return cmp.Or(strings.Compare(a.hash, b.hash), strings.Compare(a.key, b.key))
+}
+
+// geoScan builds the index on first use, it is kept current by set and unset.
+func (db *DB[T]) geoScan(cells []string, visit func(key string, point GeoPoint)) {
+	db.mutex.RLock()
+	if db.geo == nil {
+		db.mutex.RUnlock()
+		db.mutex.Lock()
+		if db.geo == nil {
+			db.geo = newGeoIndex(db.data)
+		}
+		db.mutex.Unlock()
+		db.mutex.RLock()
+	}
+	defer db.mutex.RUnlock()
+
+	for _, cell := range cells {
+		db.geo.scan(cell, func(key string) {
+			if point, ok := any(db.data[key]).(GeoPoint); ok && db.allowed(OpGet, key) {
+				visit(key, point)
+			}
+		})
+	}
+}
+
+// geoScan builds the index on first use, it is kept current by reindex and dropped on reload.
+func (db *DBCache[T, EncoderT, DecoderT]) geoScan(cells []string, visit func(key string, point GeoPoint)) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		db.report("near", "", err)
+	}
+	if db.geo == nil {
+		db.geo = newGeoIndex(db.data)
+	}
+	for _, cell := range cells {
+		db.geo.scan(cell, func(key string) {
+			if point, ok := any(db.data[key]).(GeoPoint); ok && db.check(OpGet, key) == nil {
+				visit(key, point)
+			}
+		})
+	}
+}
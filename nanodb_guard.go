@@ -0,0 +1,24 @@
+package nanodb
+
+type Op int
+
+const (
+	OpGet Op = iota
+	OpAdd
+	OpDel
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpGet:
+		return "get"
+	case OpAdd:
+		return "add"
+	case OpDel:
+		return "del"
+	default:
+		return "unknown"
+	}
+}
+
+type Guard func(op Op, key string) error
@@ -0,0 +1,59 @@
+package nanodb
+
+// Has reports whether key holds a value without copying it. It does not count as a read in Stats.
+func (db *DB[T]) Has(key string) bool {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.has(key)
+}
+
+// ExistsAll reports whether every key holds a value, checked under a single lock.
+func (db *DB[T]) ExistsAll(keys ...string) bool {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	for _, key := range keys {
+		if !db.has(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// has has to be called with the lock held.
+func (db *DB[T]) has(key string) bool {
+	key = db.normalized(key)
+	if !db.allowed(OpGet, key) {
+		return false
+	}
+	_, ok := db.data[key]
+	return ok && (db.sampler == nil || !db.expired(key))
+}
+
+// Has reports whether key holds a value without copying it, entries kept raw by WithLazyDecoding stay raw.
+func (db *DBCache[T, EncoderT, DecoderT]) Has(key string) (bool, error) {
+	return db.ExistsAll(key)
+}
+
+// ExistsAll reports whether every key holds a value, checked under a single lock and reload.
+func (db *DBCache[T, EncoderT, DecoderT]) ExistsAll(keys ...string) (bool, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.loadKey(""); err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		key = db.normalized(key)
+		if err := db.check(OpGet, key); err != nil {
+			return false, err
+		}
+		_, decoded := db.data[key]
+		_, raw := db.raw[key]
+		if !decoded && !raw {
+			return false, nil
+		}
+	}
+	return true, nil
+}
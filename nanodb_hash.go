@@ -0,0 +1,39 @@
+package nanodb
+
+import "maps"
+
+// HSet sets one field of a map-typed value under the write lock. Readers may still hold the
+// current map, so it is copied and a write costs O(fields), and DBCache saves the whole file
+// as on any write, see BenchmarkHSet. Keep large hashes as one entry per field, under Key(key, field).
+func HSet[V any](db Store[map[string]V], key string, field string, value V) error {
+	return db.update(key, func(hash map[string]V, _ bool) (map[string]V, Op) {
+		hash = maps.Clone(hash)
+		if hash == nil {
+			hash = map[string]V{}
+		}
+		hash[field] = value
+		return hash, OpAdd
+	})
+}
+
+func HGet[V any](db Store[map[string]V], key string, field string) (V, bool, error) {
+	hash, _, err := db.read(key)
+	value, ok := hash[field]
+	return value, ok, err
+}
+
+func HGetAll[V any](db Store[map[string]V], key string) (map[string]V, error) {
+	hash, _, err := db.read(key)
+	return maps.Clone(hash), err
+}
+
+// HDel removes fields, copying the map like HSet, the entry is deleted once no fields are left.
+func HDel[V any](db Store[map[string]V], key string, fields ...string) error {
+	return db.update(key, func(hash map[string]V, _ bool) (map[string]V, Op) {
+		hash = maps.Clone(hash)
+		for _, field := range fields {
+			delete(hash, field)
+		}
+		return hash, addOrDel(len(hash) > 0)
+	})
+}
@@ -0,0 +1,37 @@
+package nanodb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrIntegrity = errors.New("nanodb: cache file integrity check failed")
+
+// WithHMAC appends an HMAC-SHA256 of the encoded payload to every save
+// and rejects cache files that fail verification with ErrIntegrity.
+func WithHMAC(key []byte) Option {
+	return func(opts *options) {
+		opts.hmacKey = key
+	}
+}
+
+func sign(key []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return append(payload, mac.Sum(nil)...)
+}
+
+func verify(key []byte, signed []byte) ([]byte, error) {
+	if len(signed) < sha256.Size {
+		return nil, ErrIntegrity
+	}
+	payload, sum := signed[:len(signed)-sha256.Size], signed[len(signed)-sha256.Size:]
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrIntegrity
+	}
+	return payload, nil
+}
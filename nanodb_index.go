@@ -0,0 +1,245 @@
+package nanodb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var ErrDuplicate = errors.New("nanodb: duplicate value in unique index")
+
+type secondaryIndex[T any] struct {
+	extract func(value T) any
+	unique  bool
+	keys    map[any]map[string]struct{}
+	values  map[string]any
+}
+
+// Index maintains a secondary index from the value extract returns to the keys holding it,
+// kept up to date on every mutation. A nil or non-comparable value leaves the entry out of the
+// index, integers of any width are indexed as the same number. Registering a name again replaces its index.
+//
+// New registers an index for every struct field tagged `nanodb:"index"`, named after the field,
+// and a unique one for fields tagged `nanodb:"index,unique"`. Fields that are not comparable or
+// may hold a value that is not, interfaces and structs or arrays containing them, are skipped.
+func (db *DB[T]) Index(name string, extract func(value T) any) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.addIndex(name, extract, false)
+	return db
+}
+
+// Unique is Index refusing values another key already holds: Add drops the write and reports
+// an ErrDuplicate, batches and the value helpers return it. Duplicates already stored are reported.
+func (db *DB[T]) Unique(name string, extract func(value T) any) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.addIndex(name, extract, true)
+	return db
+}
+
+// addIndex has to be called with the lock held.
+func (db *DB[T]) addIndex(name string, extract func(value T) any, unique bool) {
+	index := db.newIndex(extract)
+	index.unique = unique
+	for key, value := range db.data {
+		if indexed := index.extract(value); unique && indexed != nil && len(index.keys[indexed]) > 0 {
+			db.report("unique", key, fmt.Errorf("%w: %s %v", ErrDuplicate, name, indexed))
+		}
+		index.add(key, value)
+	}
+	db.indexes[name] = index
+}
+
+// newIndex has to be called with the lock held, the first index makes mutations maintain
+// the indexes and references.
+func (db *DB[T]) newIndex(extract func(value T) any) *secondaryIndex[T] {
+	if db.indexes == nil {
+		db.indexes = make(map[string]*secondaryIndex[T])
+		db.addObserver(func(changes []change[T]) {
+			for _, change := range changes {
+				for _, index := range db.indexes {
+					index.update(change)
+				}
+				for _, ref := range db.refs {
+					ref.index.update(change)
+				}
+			}
+		})
+	}
+	return &secondaryIndex[T]{
+		extract: func(value T) any { return indexKey(extract(value)) },
+		keys:    make(map[any]map[string]struct{}),
+		values:  make(map[string]any),
+	}
+}
+
+// Lookup returns the keys, sorted, whose value is indexed under name as value.
+func (db *DB[T]) Lookup(name string, value any) []string {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	index, ok := db.indexes[name]
+	if !ok {
+		return nil
+	}
+	var result []string
+	for key := range index.keys[indexKey(value)] {
+		if db.allowed(OpGet, key) {
+			result = append(result, key)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// checkUnique has to be called with the lock held, it checks the state after applying every op.
+func (db *DB[T]) checkUnique(ops ...batchOp[T]) error {
+	final := finalOps(ops)
+	for name, index := range db.indexes {
+		if !index.unique {
+			continue
+		}
+		claimed := make(map[any]string)
+		for key, op := range final {
+			indexed := any(nil)
+			if !op.del {
+				indexed = index.extract(op.value)
+			}
+			if indexed == nil {
+				continue
+			}
+			if _, ok := claimed[indexed]; ok {
+				return fmt.Errorf("%w: %s %v", ErrDuplicate, name, indexed)
+			}
+			claimed[indexed] = key
+			for holder := range index.keys[indexed] {
+				if _, touched := final[holder]; !touched {
+					return fmt.Errorf("%w: %s %v held by %q", ErrDuplicate, name, indexed, holder)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// finalOps keeps the last op of every key, the one deciding the state after a batch.
+func finalOps[T any](ops []batchOp[T]) map[string]batchOp[T] {
+	final := make(map[string]batchOp[T], len(ops))
+	for _, op := range ops {
+		final[op.key] = op
+	}
+	return final
+}
+
+func (index *secondaryIndex[T]) update(change change[T]) {
+	index.remove(change.key)
+	if change.exists {
+		index.add(change.key, change.value)
+	}
+}
+
+func (index *secondaryIndex[T]) add(key string, value T) {
+	indexed := index.extract(value)
+	if indexed == nil {
+		return
+	}
+	if index.keys[indexed] == nil {
+		index.keys[indexed] = make(map[string]struct{})
+	}
+	index.keys[indexed][key] = struct{}{}
+	index.values[key] = indexed
+}
+
+func (index *secondaryIndex[T]) remove(key string) {
+	indexed, ok := index.values[key]
+	if !ok {
+		return
+	}
+	delete(index.keys[indexed], key)
+	if len(index.keys[indexed]) == 0 {
+		delete(index.keys, indexed)
+	}
+	delete(index.values, key)
+}
+
+type taggedIndex[T any] struct {
+	extract func(value T) any
+	unique  bool
+}
+
+// indexKey is the map key value is indexed under: integers of every width and named integer
+// types meet as int64, or uint64 past its range, non-comparable values are left out like nil.
+func indexKey(value any) any {
+	if value == nil {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case v.CanInt():
+		return v.Int()
+	case v.CanUint() && v.Uint() <= math.MaxInt64:
+		return int64(v.Uint())
+	case v.CanUint():
+		return v.Uint()
+	case !v.Comparable():
+		return nil
+	}
+	return value
+}
+
+// strictlyComparable reports whether every value of typ can be a map key, interfaces can hold values that cannot.
+func strictlyComparable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Interface:
+		return false
+	case reflect.Array:
+		return strictlyComparable(typ.Elem())
+	case reflect.Struct:
+		for i := range typ.NumField() {
+			if !strictlyComparable(typ.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return typ.Comparable()
+}
+
+// taggedIndexes finds the strictly comparable fields of struct T, or of the struct T points to, tagged `nanodb:"index"`.
+func taggedIndexes[T any]() map[string]taggedIndex[T] {
+	typ := reflect.TypeFor[T]()
+	pointer := typ.Kind() == reflect.Pointer
+	if pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	indexes := make(map[string]taggedIndex[T])
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		options := strings.Split(field.Tag.Get("nanodb"), ",")
+		if !field.IsExported() || !strictlyComparable(field.Type) || !slices.Contains(options, "index") {
+			continue
+		}
+		extract := func(value T) any {
+			v := reflect.ValueOf(value)
+			if pointer {
+				if v.IsNil() {
+					return nil
+				}
+				v = v.Elem()
+			}
+			return v.Field(i).Interface()
+		}
+		indexes[field.Name] = taggedIndex[T]{extract: extract, unique: slices.Contains(options, "unique")}
+	}
+	return indexes
+}
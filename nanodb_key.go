@@ -0,0 +1,65 @@
+package nanodb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const keySeparator = '/'
+
+var ErrKey = errors.New("nanodb: malformed composite key")
+
+// Key joins parts, formatted with fmt.Sprint, with "/". Separators and path.Match metacharacters
+// inside a part are percent-escaped, so ParseKey returns the parts as they were and patterns
+// passed to Trigger or Watch only match whole parts.
+func Key(parts ...any) string {
+	builder := strings.Builder{}
+	for i, part := range parts {
+		if i > 0 {
+			builder.WriteByte(keySeparator)
+		}
+		for _, b := range []byte(fmt.Sprint(part)) {
+			if strings.IndexByte("%/*?[]\\", b) >= 0 {
+				fmt.Fprintf(&builder, "%%%02X", b)
+				continue
+			}
+			builder.WriteByte(b)
+		}
+	}
+	return builder.String()
+}
+
+// KeyPrefix is Key with a trailing separator, it matches the keys under parts and not "user/10" for "user", 1.
+func KeyPrefix(parts ...any) string {
+	return Key(parts...) + string(keySeparator)
+}
+
+// ParseKey splits a key built with Key back into its unescaped parts.
+func ParseKey(key string) ([]string, error) {
+	parts := strings.Split(key, string(keySeparator))
+	for i, part := range parts {
+		if !strings.Contains(part, "%") {
+			continue
+		}
+		unescaped := make([]byte, 0, len(part))
+		for j := 0; j < len(part); j++ {
+			if part[j] != '%' {
+				unescaped = append(unescaped, part[j])
+				continue
+			}
+			if j+2 >= len(part) {
+				return nil, fmt.Errorf("%w: truncated escape in %q", ErrKey, key)
+			}
+			b, err := strconv.ParseUint(part[j+1:j+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad escape in %q", ErrKey, key)
+			}
+			unescaped = append(unescaped, byte(b))
+			j += 2
+		}
+		parts[i] = string(unescaped)
+	}
+	return parts, nil
+}
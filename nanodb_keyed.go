@@ -0,0 +1,98 @@
+package nanodb
+
+import (
+	"iter"
+	"reflect"
+	"strconv"
+)
+
+// KeyType are the integer and string kinds Keyed converts to and from DB keys without loss.
+type KeyType interface {
+	~string | ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// Keyed is a DB indexed by K, keys are stored as their decimal form so guards, quotas, triggers
+// and files keep working on strings. Everything besides the keyed calls is configured on DB().
+type Keyed[K KeyType, V any] struct {
+	db *DB[V]
+}
+
+func NewKeyed[K KeyType, V any]() *Keyed[K, V] {
+	return &Keyed[K, V]{db: New[V]()}
+}
+
+// DB returns the underlying store, for Timeout, Guard, Watch and the other string-keyed calls.
+func (keyed *Keyed[K, V]) DB() *DB[V] {
+	return keyed.db
+}
+
+func (keyed *Keyed[K, V]) Get(key K) V {
+	return keyed.db.Get(formatKey(key))
+}
+
+func (keyed *Keyed[K, V]) TryGet(key K) (V, bool) {
+	return keyed.db.TryGet(formatKey(key))
+}
+
+func (keyed *Keyed[K, V]) Add(key K, value V) *Keyed[K, V] {
+	keyed.db.Add(formatKey(key), value)
+	return keyed
+}
+
+func (keyed *Keyed[K, V]) Del(key K) *Keyed[K, V] {
+	keyed.db.Del(formatKey(key))
+	return keyed
+}
+
+func (keyed *Keyed[K, V]) Has(key K) bool {
+	return keyed.db.Has(formatKey(key))
+}
+
+func (keyed *Keyed[K, V]) Len() int {
+	return keyed.db.Len()
+}
+
+// Seq2 skips keys added through DB() that do not parse as K.
+func (keyed *Keyed[K, V]) Seq2() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for raw, value := range keyed.db.Seq2() {
+			key, ok := parseKey[K](raw)
+			if ok && !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+func formatKey[K KeyType](key K) string {
+	v := reflect.ValueOf(key)
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.CanInt():
+		return strconv.FormatInt(v.Int(), 10)
+	default:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+}
+
+func parseKey[K KeyType](raw string) (key K, ok bool) {
+	v := reflect.ValueOf(&key).Elem()
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.CanInt():
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return key, false
+		}
+		v.SetInt(n)
+	default:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return key, false
+		}
+		v.SetUint(n)
+	}
+	return key, true
+}
@@ -0,0 +1,117 @@
+package nanodb
+
+import (
+	"encoding/json"
+	"io"
+	"iter"
+	"maps"
+)
+
+// WithLazyDecoding keeps the entries read from the cache file as json.RawMessage and decodes
+// each one into T on first access. Reads of a single key decode only that key, anything walking
+// every entry decodes them all. It needs a JSON cache file and is ignored with a Resolver.
+func WithLazyDecoding() Option {
+	return func(opts *options) {
+		opts.lazy = true
+	}
+}
+
+// raws reports whether entries are read as json.RawMessage first, eager projections decode them right away.
+func (db *DBCache[T, EncoderT, DecoderT]) raws() bool {
+	return db.lazy || db.projected != nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) decodeRaw(r io.Reader) error {
+	raw := make(map[string]json.RawMessage)
+	if err := db.newDecoder(r).Decode(&raw); err != nil {
+		return err
+	}
+	if db.raw == nil {
+		db.raw = make(map[string]json.RawMessage, len(raw))
+	}
+	for key, value := range raw {
+		delete(db.data, key)
+		db.raw[key] = value
+	}
+	if !db.lazy {
+		return db.materialize()
+	}
+	return nil
+}
+
+// decodeKey has to be called with the lock held, it moves key from the raw entries to data.
+func (db *DBCache[T, EncoderT, DecoderT]) decodeKey(key string) error {
+	raw, ok := db.raw[key]
+	if !ok {
+		return nil
+	}
+	if db.projected != nil {
+		var err error
+		if raw, err = project(raw, db.projected); err != nil {
+			return err
+		}
+	}
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	delete(db.raw, key)
+	db.data[key] = value
+	db.reindex(key)
+	return nil
+}
+
+// materialize has to be called with the lock held, it decodes every raw entry.
+func (db *DBCache[T, EncoderT, DecoderT]) materialize() error {
+	for key := range db.raw {
+		if err := db.decodeKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forget has to be called with the lock held, key is about to be overwritten or deleted.
+func (db *DBCache[T, EncoderT, DecoderT]) forget(key string) {
+	delete(db.raw, key)
+}
+
+// keys has to be called with the lock held, it walks decoded and raw entries without decoding.
+func (db *DBCache[T, EncoderT, DecoderT]) keys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for key := range maps.Keys(db.data) {
+			if !yield(key) {
+				return
+			}
+		}
+		for key := range maps.Keys(db.raw) {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// has reports whether key is stored, decoded or still raw.
+func (db *DBCache[T, EncoderT, DecoderT]) has(key string) bool {
+	if _, ok := db.data[key]; ok {
+		return true
+	}
+	_, ok := db.raw[key]
+	return ok
+}
+
+// encoded is what gets saved, the raw entries are written back as they were read.
+func (db *DBCache[T, EncoderT, DecoderT]) encoded() any {
+	if len(db.raw) == 0 {
+		return db.data
+	}
+	merged := make(map[string]any, len(db.data)+len(db.raw))
+	for key, value := range db.raw {
+		merged[key] = value
+	}
+	for key, value := range db.data {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,122 @@
+package nanodb
+
+import (
+	"errors"
+	"maps"
+	"time"
+)
+
+// Migrate copies every entry of from into to, entries keep their insertion time and so their remaining TTL.
+// Wrap writes with DualWrite while migrating so nothing written in between is lost. An entry
+// refused by the guard, a quota, a unique index or a reference of to fails the whole migration.
+func Migrate[T any](from, to Store[T]) error {
+	data, lifetimes, err := from.snapshot()
+	if err != nil {
+		return err
+	}
+	return to.restore(data, lifetimes)
+}
+
+// DualWrite reads from primary and writes to both stores, for moving between stores without downtime.
+type DualWrite[T any] struct {
+	primary   Store[T]
+	secondary Store[T]
+}
+
+func NewDualWrite[T any](primary, secondary Store[T]) *DualWrite[T] {
+	return &DualWrite[T]{primary: primary, secondary: secondary}
+}
+
+func (dual *DualWrite[T]) TryGet(key string) (T, bool, error) {
+	return dual.primary.read(key)
+}
+
+func (dual *DualWrite[T]) Add(key string, value T) error {
+	set := func(T, bool) (T, Op) { return value, OpAdd }
+	return errors.Join(dual.primary.update(key, set), dual.secondary.update(key, set))
+}
+
+func (dual *DualWrite[T]) Del(key string) error {
+	del := func(value T, _ bool) (T, Op) { return value, OpDel }
+	return errors.Join(dual.primary.update(key, del), dual.secondary.update(key, del))
+}
+
+func (db *DB[T]) snapshot() (map[string]T, map[string]time.Time, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return maps.Clone(db.data), maps.Clone(db.lifetimes), nil
+}
+
+func (db *DB[T]) restore(data map[string]T, lifetimes map[string]time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	ops := make([]batchOp[T], 0, len(data))
+	restored := make(map[string]time.Time, len(data))
+	for key, value := range data {
+		lifetime := lifetimeOr(lifetimes, key)
+		key = db.normalized(key)
+		value = resolve(db.resolver, db.data, db.lifetimes, key, Versioned[T]{Value: value, Modified: lifetime})
+		ops = append(ops, batchOp[T]{key: key, value: value})
+		restored[key] = lifetime
+	}
+	if err := stage(ops, db.check, db.quotas, db.quotaUsage(), jsonSize[T]); err != nil {
+		return err
+	}
+	if err := db.checkUnique(ops...); err != nil {
+		return err
+	}
+	if err := db.checkRefs(ops...); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		db.set(op.key, op.value, restored[op.key])
+	}
+	return nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) snapshot() (map[string]T, map[string]time.Time, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.load(); err != nil {
+		return nil, nil, err
+	}
+	return maps.Clone(db.data), maps.Clone(db.lifetimes), nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) restore(data map[string]T, lifetimes map[string]time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.loadForWrite(); err != nil {
+		return err
+	}
+	if err := db.materialize(); err != nil {
+		return err
+	}
+	ops := make([]batchOp[T], 0, len(data))
+	restored := make(map[string]time.Time, len(data))
+	for key, value := range data {
+		lifetime := lifetimeOr(lifetimes, key)
+		key = db.normalized(key)
+		value = resolve(db.resolver, db.data, db.lifetimes, key, Versioned[T]{Value: value, Modified: lifetime})
+		ops = append(ops, batchOp[T]{key: key, value: value})
+		restored[key] = lifetime
+	}
+	if err := stage(ops, db.check, db.quotas, db.quotaUsage(), db.size); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		db.set(op.key, op.value, restored[op.key])
+	}
+	return db.save()
+}
+
+func lifetimeOr(lifetimes map[string]time.Time, key string) time.Time {
+	if lifetime, ok := lifetimes[key]; ok {
+		return lifetime
+	}
+	return time.Now()
+}
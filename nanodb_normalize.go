@@ -0,0 +1,101 @@
+package nanodb
+
+import (
+	"maps"
+	"strings"
+)
+
+// NormalizeKeys rewrites every key passed to Get, Add, Del, batches and the value helpers,
+// the quota, usage and RequireRef prefixes and Watch patterns with the normalizers, applied in order.
+// Stored keys are rewritten too, when two of them collide one value is kept. For caches keyed
+// by user input pass LowerCase and norm.NFC.String from golang.org/x/text/unicode/norm: Unicode
+// normalization needs the composition tables of x/text, which the standard library does not ship
+// and nanodb does not depend on.
+func (db *DB[T]) NormalizeKeys(normalizers ...func(key string) string) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.normalize = chainNormalizers(normalizers)
+	for key, value := range maps.Clone(db.data) {
+		if normalized := db.normalized(key); normalized != key {
+			lifetime := db.lifetimes[key]
+			db.unset(key)
+			db.set(normalized, value, lifetime)
+		}
+	}
+	// References index the normalized key they point at, which the new normalizers change.
+	for i, ref := range db.refs {
+		db.refs[i].from, db.refs[i].to = db.normalized(ref.from), db.normalized(ref.to)
+		for key, value := range db.data {
+			ref.index.remove(key)
+			ref.index.add(key, value)
+		}
+	}
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) NormalizeKeys(normalizers ...func(key string) string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.normalize = chainNormalizers(normalizers)
+	if err := db.load(); err != nil {
+		return err
+	}
+	changed := false
+	for key, value := range maps.Clone(db.data) {
+		if normalized := db.normalized(key); normalized != key {
+			lifetime := lifetimeOr(db.lifetimes, key)
+			db.unset(key)
+			db.set(normalized, value, lifetime)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return db.save()
+}
+
+// LowerCase is a key normalizer making keys case-insensitive.
+func LowerCase(key string) string {
+	return strings.ToLower(key)
+}
+
+func chainNormalizers(normalizers []func(key string) string) func(key string) string {
+	if len(normalizers) == 0 {
+		return nil
+	}
+	return func(key string) string {
+		for _, normalize := range normalizers {
+			key = normalize(key)
+		}
+		return key
+	}
+}
+
+func (db *DB[T]) normalized(key string) string {
+	if db.normalize == nil {
+		return key
+	}
+	return db.normalize(key)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) normalized(key string) string {
+	if db.normalize == nil {
+		return key
+	}
+	return db.normalize(key)
+}
+
+func normalizedOps[T any](ops []batchOp[T], normalize func(key string) string) []batchOp[T] {
+	if normalize == nil {
+		return ops
+	}
+	result := make([]batchOp[T], len(ops))
+	for i, op := range ops {
+		op.key = normalize(op.key)
+		result[i] = op
+	}
+	return result
+}
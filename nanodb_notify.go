@@ -0,0 +1,124 @@
+package nanodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Expired is an entry removed by the timeout or a CleanupPolicy, as handed to a Sink.
+type Expired[T any] struct {
+	Key   string    `json:"key"`
+	Value T         `json:"value"`
+	Meta  EntryMeta `json:"meta"`
+}
+
+// Sink receives expired entries in batches, wrap it with Retry to retry failed batches.
+type Sink[T any] func(batch []Expired[T]) error
+
+type notifier[T any] struct {
+	timer   string
+	sink    Sink[T]
+	size    int
+	delay   time.Duration
+	pending []Expired[T]
+}
+
+// NotifyExpired hands expired and cleaned up entries to sink, explicit Del calls are not notified.
+// Entries are collected until size of them are pending or delay has passed since the first one,
+// the sink runs outside of the lock on the worker pool and its errors are reported as "notify".
+// Every call adds another sink, Shutdown flushes the pending batches.
+func (db *DB[T]) NotifyExpired(sink Sink[T], size int, delay time.Duration) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.notifiers = append(db.notifiers, &notifier[T]{
+		timer: "notify/" + strconv.Itoa(len(db.notifiers)),
+		sink:  sink,
+		size:  max(size, 1),
+		delay: delay,
+	})
+	return db
+}
+
+// ToChannel is a Sink sending every entry to ch, a full channel blocks a pool worker.
+func ToChannel[T any](ch chan<- Expired[T]) Sink[T] {
+	return func(batch []Expired[T]) error {
+		for _, expired := range batch {
+			ch <- expired
+		}
+		return nil
+	}
+}
+
+// Webhook is a Sink posting every batch to url as a JSON array, any non-2xx response is an error.
+// A nil client means http.DefaultClient.
+func Webhook[T any](client *http.Client, url string) Sink[T] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(batch []Expired[T]) error {
+		body, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		response, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("nanodb: webhook %s: %s", url, response.Status)
+		}
+		return nil
+	}
+}
+
+// Retry calls sink up to attempts times, sleeping backoff after the first failure and doubling it
+// after every next one, the last error is returned.
+func Retry[T any](sink Sink[T], attempts int, backoff time.Duration) Sink[T] {
+	return func(batch []Expired[T]) (err error) {
+		for attempt := range max(attempts, 1) {
+			if attempt > 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			if err = sink(batch); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// notifyExpired has to be called with the lock held.
+func (db *DB[T]) notifyExpired(expired Expired[T]) {
+	for _, notifier := range db.notifiers {
+		notifier.pending = append(notifier.pending, expired)
+		switch {
+		case len(notifier.pending) >= notifier.size:
+			db.flushExpired(notifier)
+		case len(notifier.pending) == 1:
+			db.schedules.schedule(notifier.timer, notifier.delay, func() { db.flushExpired(notifier) })
+		}
+	}
+}
+
+// flushExpired has to be called with the lock held.
+func (db *DB[T]) flushExpired(notifier *notifier[T]) {
+	db.schedules.cancel(notifier.timer)
+	if len(notifier.pending) == 0 {
+		return
+	}
+
+	batch, sink := notifier.pending, notifier.sink
+	notifier.pending = nil
+	db.workerPool().submit(func() {
+		if err := sink(batch); err != nil {
+			db.report("notify", batch[0].Key, err)
+		}
+	})
+}
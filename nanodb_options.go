@@ -0,0 +1,21 @@
+package nanodb
+
+// Option configures a DBCache at construction, before the cache file is first read.
+type Option func(*options)
+
+type options struct {
+	hmacKey    []byte
+	seed       string
+	envPrefix  string
+	fsys       FS
+	lazy       bool
+	projection []string
+}
+
+func collectOptions(opts []Option) options {
+	result := options{fsys: OSFS{}}
+	for _, opt := range opts {
+		opt(&result)
+	}
+	return result
+}
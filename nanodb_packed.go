@@ -0,0 +1,255 @@
+package nanodb
+
+import (
+	"hash/maphash"
+	"iter"
+	"sync"
+	"time"
+)
+
+// Fixed are the value types Packed stores inline.
+type Fixed interface {
+	~bool | ~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~complex64 | ~complex128
+}
+
+// Packed keeps small fixed-size values in one slice, the keys' bytes in one arena
+// and an open-addressing table of 4-byte entry numbers hashed by key, so an entry costs its value,
+// its key bytes, an 8-byte key span and about 6 bytes of table instead of a map slot
+// plus a separately allocated key. Del moves the last entry into the freed slot
+// and compacts the arena once half of it is freed. It holds up to 2^32-1 entries and 4GB of keys.
+// It has no timeout, guard, callbacks or panic policy, it is a Store for the value helpers and Migrate.
+type Packed[V Fixed] struct {
+	mutex   *sync.RWMutex
+	seed    maphash.Seed
+	slots   []uint32  // entry number + 1 per slot, 0 marks a free slot
+	spans   []keySpan // where each entry's key lives in the arena
+	arena   []byte
+	values  []V
+	garbage int // bytes of deleted keys still in the arena
+}
+
+// keySpan is where a key lives in the arena.
+type keySpan struct {
+	offset, length uint32
+}
+
+var _ Store[int] = (*Packed[int])(nil)
+
+// NewPacked preallocates room for capacity entries.
+func NewPacked[V Fixed](capacity int) *Packed[V] {
+	db := &Packed[V]{
+		mutex:  &sync.RWMutex{},
+		seed:   maphash.MakeSeed(),
+		spans:  make([]keySpan, 0, capacity),
+		values: make([]V, 0, capacity),
+	}
+	db.rehash(tableSize(capacity))
+	return db
+}
+
+func (db *Packed[V]) Get(key string) V {
+	value, _ := db.TryGet(key)
+	return value
+}
+
+func (db *Packed[V]) TryGet(key string) (V, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if _, i, ok := db.find(key); ok {
+		return db.values[i], true
+	}
+	var zero V
+	return zero, false
+}
+
+func (db *Packed[V]) Add(key string, value V) *Packed[V] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.set(key, value)
+	return db
+}
+
+func (db *Packed[V]) Del(key string) *Packed[V] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.unset(key)
+	return db
+}
+
+func (db *Packed[V]) Len() int {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return len(db.values)
+}
+
+func (db *Packed[V]) Seq2() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		db.mutex.RLock()
+		defer db.mutex.RUnlock()
+
+		for i, value := range db.values {
+			if !yield(string(db.key(i)), value) {
+				return
+			}
+		}
+	}
+}
+
+func (db *Packed[V]) read(key string) (V, bool, error) {
+	value, ok := db.TryGet(key)
+	return value, ok, nil
+}
+
+func (db *Packed[V]) update(key string, fn func(value V, ok bool) (V, Op)) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var current V
+	_, i, ok := db.find(key)
+	if ok {
+		current = db.values[i]
+	}
+	switch value, op := fn(current, ok); op {
+	case OpAdd:
+		db.set(key, value)
+	case OpDel:
+		db.unset(key)
+	}
+	return nil
+}
+
+func (db *Packed[V]) snapshot() (map[string]V, map[string]time.Time, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data := make(map[string]V, len(db.values))
+	for i, value := range db.values {
+		data[string(db.key(i))] = value
+	}
+	return data, nil, nil
+}
+
+func (db *Packed[V]) restore(data map[string]V, _ map[string]time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for key, value := range data {
+		db.set(key, value)
+	}
+	return nil
+}
+
+// set has to be called with the lock held.
+func (db *Packed[V]) set(key string, value V) {
+	if _, i, ok := db.find(key); ok {
+		db.values[i] = value
+		return
+	}
+	if (len(db.values)+1)*4 > len(db.slots)*3 {
+		db.rehash(len(db.slots) * 2)
+	}
+	slot, _, _ := db.find(key)
+	db.slots[slot] = uint32(len(db.values) + 1)
+	db.spans = append(db.spans, keySpan{uint32(len(db.arena)), uint32(len(key))})
+	db.arena = append(db.arena, key...)
+	db.values = append(db.values, value)
+}
+
+// unset has to be called with the lock held.
+func (db *Packed[V]) unset(key string) {
+	slot, i, ok := db.find(key)
+	if !ok {
+		return
+	}
+	db.free(slot)
+	db.garbage += int(db.spans[i].length)
+
+	last := len(db.values) - 1
+	if i != last {
+		db.slots[db.slotOf(last)] = uint32(i + 1)
+		db.spans[i], db.values[i] = db.spans[last], db.values[last]
+	}
+	db.spans, db.values = db.spans[:last], db.values[:last]
+	if db.garbage > len(db.arena)/2 {
+		db.compact()
+	}
+}
+
+// find returns the slot holding key, or the free slot it would take.
+func (db *Packed[V]) find(key string) (slot int, i int, ok bool) {
+	mask := len(db.slots) - 1
+	for slot = int(maphash.String(db.seed, key)) & mask; db.slots[slot] != 0; slot = (slot + 1) & mask {
+		if i = int(db.slots[slot] - 1); string(db.key(i)) == key {
+			return slot, i, true
+		}
+	}
+	return slot, -1, false
+}
+
+// slotOf returns the slot holding entry i.
+func (db *Packed[V]) slotOf(i int) int {
+	mask := len(db.slots) - 1
+	slot := db.home(i)
+	for int(db.slots[slot]) != i+1 {
+		slot = (slot + 1) & mask
+	}
+	return slot
+}
+
+// free empties slot and shifts back the entries probed past it, so lookups need no tombstones.
+func (db *Packed[V]) free(slot int) {
+	mask := len(db.slots) - 1
+	db.slots[slot] = 0
+	for next := (slot + 1) & mask; db.slots[next] != 0; next = (next + 1) & mask {
+		// The entry at next stays unless slot lies cyclically between its home and next.
+		if home := db.home(int(db.slots[next] - 1)); (next-home)&mask >= (next-slot)&mask {
+			db.slots[slot], db.slots[next] = db.slots[next], 0
+			slot = next
+		}
+	}
+}
+
+func (db *Packed[V]) home(i int) int {
+	return int(maphash.Bytes(db.seed, db.key(i))) & (len(db.slots) - 1)
+}
+
+func (db *Packed[V]) key(i int) []byte {
+	span := db.spans[i]
+	return db.arena[span.offset : span.offset+span.length]
+}
+
+func (db *Packed[V]) rehash(size int) {
+	db.slots = make([]uint32, size)
+	for i := range db.values {
+		slot := db.home(i)
+		for db.slots[slot] != 0 {
+			slot = (slot + 1) & (size - 1)
+		}
+		db.slots[slot] = uint32(i + 1)
+	}
+}
+
+func (db *Packed[V]) compact() {
+	arena := make([]byte, 0, len(db.arena)-db.garbage)
+	for i := range db.spans {
+		key := db.key(i)
+		db.spans[i].offset = uint32(len(arena))
+		arena = append(arena, key...)
+	}
+	db.arena, db.garbage = arena, 0
+}
+
+// tableSize is the smallest power of two keeping capacity entries at most 3/4 full.
+func tableSize(capacity int) int {
+	size := 8
+	for size*3 < capacity*4 {
+		size *= 2
+	}
+	return size
+}
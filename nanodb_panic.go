@@ -0,0 +1,52 @@
+package nanodb
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicPolicy decides what happens when a user callback (guard, codec, update function, observer) panics.
+type PanicPolicy int
+
+const (
+	// PanicPropagate lets the panic unwind into the caller, as any Go code would.
+	PanicPropagate PanicPolicy = iota
+	// PanicRecover converts the panic into a *PanicError returned to the caller,
+	// or logged when the operation has no error to return.
+	PanicRecover
+)
+
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("nanodb: recovered panic: %v", err.Value)
+}
+
+// recoverPanic has to be deferred directly for recover to see the panic.
+func recoverPanic(policy PanicPolicy, err *error) {
+	if policy != PanicRecover {
+		return
+	}
+	if recovered := recover(); recovered != nil {
+		*err = &PanicError{Value: recovered, Stack: debug.Stack()}
+	}
+}
+
+func (db *DB[T]) Panics(policy PanicPolicy) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.panics = policy
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Panics(policy PanicPolicy) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.panics = policy
+	return db
+}
@@ -0,0 +1,210 @@
+package nanodb
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Overflow decides what happens to a callback submitted while every worker is busy and the queue is full.
+type Overflow int
+
+const (
+	// OverflowQueue parks the callback in a backlog the workers drain as the queue frees up.
+	OverflowQueue Overflow = iota
+	// OverflowDrop discards the callback and logs it.
+	OverflowDrop
+)
+
+// pool runs callbacks (triggers, refreshes) outside of the DB lock on a bounded set of goroutines,
+// a panicking callback is logged and does not take the worker down.
+type pool struct {
+	workers  int
+	overflow Overflow
+	tasks    chan func()
+	backlog  []func()
+	done     chan struct{}
+	running  *sync.WaitGroup
+	mutex    *sync.Mutex
+	started  bool
+	stopped  bool
+	previous *pool // replaced pool still draining, stop waits for it
+	next     *pool // pool that took over, tasks submitted here go there
+	report   func(op string, key string, err error)
+}
+
+func newPool(workers int, overflow Overflow, report func(op string, key string, err error)) *pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &pool{
+		workers:  workers,
+		overflow: overflow,
+		tasks:    make(chan func(), workers*64),
+		done:     make(chan struct{}),
+		running:  &sync.WaitGroup{},
+		mutex:    &sync.Mutex{},
+		report:   report,
+	}
+}
+
+// Workers sets how many goroutines run callbacks. Callbacks already queued run on the previous workers,
+// which exit once they are done, and Shutdown waits for them.
+func (db *DB[T]) Workers(n int) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.replacePool(newPool(n, db.workerPool().overflow, db.report))
+	return db
+}
+
+func (db *DB[T]) Overflow(overflow Overflow) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.replacePool(newPool(db.workerPool().workers, overflow, db.report))
+	return db
+}
+
+// workerPool has to be called with the lock held.
+func (db *DB[T]) workerPool() *pool {
+	if db.pool == nil {
+		db.pool = newPool(0, OverflowQueue, db.report)
+	}
+	return db.pool
+}
+
+// replacePool has to be called with the lock held.
+func (db *DB[T]) replacePool(next *pool) {
+	if db.pool != nil {
+		db.pool.handOver(next)
+	}
+	db.pool = next
+}
+
+// handOver lets the workers drain the queued tasks and exit, tasks submitted later go to next.
+func (p *pool) handOver(next *pool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.next, next.previous = next, p
+	p.close()
+}
+
+// submit never blocks, callers may hold the DB lock and the task may need it.
+// Tasks submitted after stop are dropped.
+func (p *pool) submit(task func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.next != nil {
+		p.next.submit(task)
+		return
+	}
+	if p.stopped {
+		return
+	}
+	if !p.started {
+		p.started = true
+		p.running.Add(p.workers)
+		for range p.workers {
+			go p.work()
+		}
+	}
+
+	if len(p.backlog) == 0 {
+		select {
+		case p.tasks <- task:
+			return
+		default:
+		}
+	}
+	if p.overflow == OverflowDrop {
+		p.report("pool", "", ErrCallbackDropped)
+		return
+	}
+	p.backlog = append(p.backlog, task)
+}
+
+// stop lets the workers drain the queued tasks and waits for them to exit.
+func (p *pool) stop(ctx context.Context) error {
+	p.mutex.Lock()
+	p.close()
+	previous := p.previous
+	p.mutex.Unlock()
+
+	if previous != nil {
+		if err := previous.stop(ctx); err != nil {
+			return err
+		}
+	}
+	return waitContext(ctx, p.running)
+}
+
+// close has to be called with the lock held.
+func (p *pool) close() {
+	if !p.stopped {
+		p.stopped = true
+		close(p.done)
+	}
+}
+
+func (p *pool) work() {
+	defer p.running.Done()
+	for {
+		select {
+		case task := <-p.tasks:
+			p.run(task)
+			p.refill()
+		case <-p.done:
+			for task := p.pending(); task != nil; task = p.pending() {
+				p.run(task)
+			}
+			return
+		}
+	}
+}
+
+// refill moves the backlog into the queue as far as it fits.
+func (p *pool) refill() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for len(p.backlog) > 0 {
+		select {
+		case p.tasks <- p.backlog[0]:
+			p.backlog[0], p.backlog = nil, p.backlog[1:]
+		default:
+			return
+		}
+	}
+}
+
+// pending takes the next queued task, nil once the queue and the backlog are empty.
+func (p *pool) pending() func() {
+	select {
+	case task := <-p.tasks:
+		return task
+	default:
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.backlog) == 0 {
+		return nil
+	}
+	task := p.backlog[0]
+	p.backlog[0], p.backlog = nil, p.backlog[1:]
+	return task
+}
+
+func (p *pool) run(task func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			p.report("callback", "", &PanicError{Value: recovered, Stack: debug.Stack()})
+		}
+	}()
+	task()
+}
@@ -0,0 +1,61 @@
+package nanodb
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var ErrProjected = errors.New("nanodb: projected cache is read-only")
+
+// WithProjection decodes only the named fields of struct values, the others stay zero.
+// Names are Go field names, json tags are honored. It needs a JSON cache file, and since saving
+// would drop the skipped fields, writes to a projected cache fail with ErrProjected.
+func WithProjection(fields ...string) Option {
+	return func(opts *options) {
+		opts.projection = fields
+	}
+}
+
+// projectedKeys maps the Go field names of T to the JSON keys they are encoded as.
+func projectedKeys[T any](fields []string) []string {
+	if fields == nil {
+		return nil
+	}
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fields
+	}
+	keys := make([]string, 0, len(fields))
+	for _, name := range fields {
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			keys = append(keys, name)
+			continue
+		}
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+			name = tag
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// project drops every field of the raw object not in keys, matching them as encoding/json does.
+func project(raw json.RawMessage, keys []string) (json.RawMessage, error) {
+	object := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	for field := range object {
+		if !slices.ContainsFunc(keys, func(key string) bool { return strings.EqualFold(key, field) }) {
+			delete(object, field)
+		}
+	}
+	return json.Marshal(object)
+}
@@ -0,0 +1,110 @@
+package nanodb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrQueryPath = errors.New("nanodb: invalid query path")
+
+// Query returns the sorted keys whose value, seen through its JSON representation,
+// has a field at path (".status", ".user.emails[0]", "." for the value itself) accepted by match.
+func (db *DB[T]) Query(path string, match func(any) bool) ([]string, error) {
+	return query(db.Seq2(), path, match)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Query(path string, match func(any) bool) ([]string, error) {
+	return query(db.Seq2(), path, match)
+}
+
+type pathSegment struct {
+	field string
+	index int
+}
+
+func query[T any](seq iter.Seq2[string, T], path string, match func(any) bool) ([]string, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key, value := range seq {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("nanodb: query %q: %w", key, err)
+		}
+		var decoded any
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			return nil, fmt.Errorf("nanodb: query %q: %w", key, err)
+		}
+
+		if field, ok := lookupPath(decoded, segments); ok && match(field) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	if !strings.HasPrefix(path, ".") {
+		return nil, fmt.Errorf("%w: %q must start with '.'", ErrQueryPath, path)
+	}
+
+	segments := []pathSegment{}
+	for _, part := range strings.Split(path[1:], ".") {
+		if part == "" {
+			if path == "." {
+				break
+			}
+			return nil, fmt.Errorf("%w: empty segment in %q", ErrQueryPath, path)
+		}
+
+		field, rest, _ := strings.Cut(part, "[")
+		if field != "" {
+			segments = append(segments, pathSegment{field: field, index: -1})
+		}
+		for rest != "" {
+			number, tail, ok := strings.Cut(rest, "]")
+			index, err := strconv.Atoi(number)
+			if !ok || err != nil || index < 0 {
+				return nil, fmt.Errorf("%w: bad index in %q", ErrQueryPath, path)
+			}
+			segments = append(segments, pathSegment{index: index})
+			if rest = strings.TrimPrefix(tail, "["); rest == tail && tail != "" {
+				return nil, fmt.Errorf("%w: unexpected %q in %q", ErrQueryPath, tail, path)
+			}
+		}
+	}
+	return segments, nil
+}
+
+func lookupPath(value any, segments []pathSegment) (any, bool) {
+	for _, segment := range segments {
+		switch node := value.(type) {
+		case map[string]any:
+			if segment.index >= 0 {
+				return nil, false
+			}
+			field, ok := node[segment.field]
+			if !ok {
+				return nil, false
+			}
+			value = field
+		case []any:
+			if segment.index < 0 || segment.index >= len(node) {
+				return nil, false
+			}
+			value = node[segment.index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,157 @@
+package nanodb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+var ErrQuotaExceeded = errors.New("nanodb: quota exceeded")
+
+// Quota limits the keys sharing a prefix, zero fields are unlimited.
+// Bytes are measured as the key length plus the encoded value length.
+type Quota struct {
+	MaxEntries int
+	MaxBytes   int
+}
+
+type Usage struct {
+	Entries int
+	Bytes   int
+}
+
+type QuotaError struct {
+	Prefix string
+	Quota  Quota
+	Usage  Usage
+}
+
+func (err *QuotaError) Error() string {
+	return fmt.Sprintf(
+		"nanodb: quota exceeded for prefix %q (entries %d/%d, bytes %d/%d)",
+		err.Prefix, err.Usage.Entries, err.Quota.MaxEntries, err.Usage.Bytes, err.Quota.MaxBytes,
+	)
+}
+
+func (err *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// usageCounters keep the running usage of every quota prefix and the measured size of every
+// key under one, so a write is checked against them instead of remeasuring the whole prefix.
+// A staged copy records its changes on top of base, deleted keys have a negative size.
+type usageCounters struct {
+	sizes map[string]int
+	usage map[string]Usage
+	base  *usageCounters
+}
+
+func countUsage[T any](quotas map[string]Quota, data map[string]T, size func(T) int) *usageCounters {
+	counters := &usageCounters{sizes: make(map[string]int), usage: make(map[string]Usage, len(quotas))}
+	for key, value := range data {
+		track(counters, quotas, key, value, true, size)
+	}
+	return counters
+}
+
+// staged returns a copy that batches apply their ops to before committing any of them.
+func (counters *usageCounters) staged() *usageCounters {
+	return &usageCounters{sizes: make(map[string]int), usage: maps.Clone(counters.usage), base: counters}
+}
+
+func (counters *usageCounters) size(key string) (int, bool) {
+	if size, ok := counters.sizes[key]; ok {
+		return size, size >= 0
+	}
+	if counters.base != nil {
+		return counters.base.size(key)
+	}
+	return 0, false
+}
+
+// track accounts for key now holding value, or for its deletion when exists is false.
+func track[T any](counters *usageCounters, quotas map[string]Quota, key string, value T, exists bool, size func(T) int) {
+	old, had := counters.size(key)
+	measured := -1
+	for prefix := range quotas {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if exists && measured < 0 {
+			measured = len(key) + size(value)
+		}
+		usage := counters.usage[prefix]
+		if had {
+			usage.Entries--
+			usage.Bytes -= old
+		}
+		if exists {
+			usage.Entries++
+			usage.Bytes += measured
+		}
+		counters.usage[prefix] = usage
+	}
+
+	switch {
+	case measured >= 0:
+		counters.sizes[key] = measured
+	case counters.base != nil:
+		counters.sizes[key] = -1
+	default:
+		delete(counters.sizes, key)
+	}
+}
+
+func checkQuotas[T any](quotas map[string]Quota, counters *usageCounters, key string, value T, size func(T) int) error {
+	old, had := counters.size(key)
+	measured := -1
+	for prefix, quota := range quotas {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if measured < 0 {
+			measured = len(key) + size(value)
+		}
+		usage := counters.usage[prefix]
+		if had {
+			usage.Entries--
+			usage.Bytes -= old
+		}
+		usage.Entries++
+		usage.Bytes += measured
+
+		if (quota.MaxEntries > 0 && usage.Entries > quota.MaxEntries) ||
+			(quota.MaxBytes > 0 && usage.Bytes > quota.MaxBytes) {
+			return &QuotaError{Prefix: prefix, Quota: quota, Usage: usage}
+		}
+	}
+	return nil
+}
+
+func usageOf[T any](data map[string]T, prefix string, size func(T) int) Usage {
+	usage := Usage{}
+	for key, value := range data {
+		if strings.HasPrefix(key, prefix) {
+			usage.Entries++
+			usage.Bytes += len(key) + size(value)
+		}
+	}
+	return usage
+}
+
+func jsonSize[T any](value T) int {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+type countingWriter int
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
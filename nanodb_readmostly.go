@@ -0,0 +1,117 @@
+package nanodb
+
+import (
+	"iter"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReadMostly keeps small hot values, like feature flags, for reads that never wait on a writer.
+// Reads load the current map through an atomic pointer without any lock or retry, writers take
+// a mutex, copy the map and publish the copy, so a write costs O(n). This is the seqlock idea
+// made safe for Go's memory model: plain reads validated by a sequence counter would be a data race.
+// Storing the value a key already holds publishes nothing. It has no timeout, guard, callbacks or
+// panic policy, it is a Store for the value helpers and Migrate.
+type ReadMostly[V comparable] struct {
+	mutex *sync.Mutex
+	data  atomic.Pointer[map[string]V]
+}
+
+var _ Store[int] = (*ReadMostly[int])(nil)
+
+func NewReadMostly[V comparable]() *ReadMostly[V] {
+	db := &ReadMostly[V]{mutex: &sync.Mutex{}}
+	db.data.Store(&map[string]V{})
+	return db
+}
+
+func (db *ReadMostly[V]) Get(key string) V {
+	value, _ := db.TryGet(key)
+	return value
+}
+
+func (db *ReadMostly[V]) TryGet(key string) (V, bool) {
+	value, ok := (*db.data.Load())[key]
+	return value, ok
+}
+
+func (db *ReadMostly[V]) Add(key string, value V) *ReadMostly[V] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.set(key, value)
+	return db
+}
+
+func (db *ReadMostly[V]) Del(key string) *ReadMostly[V] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.unset(key)
+	return db
+}
+
+func (db *ReadMostly[V]) Len() int {
+	return len(*db.data.Load())
+}
+
+// Seq2 iterates the values published when it started.
+func (db *ReadMostly[V]) Seq2() iter.Seq2[string, V] {
+	return maps.All(*db.data.Load())
+}
+
+func (db *ReadMostly[V]) read(key string) (V, bool, error) {
+	value, ok := db.TryGet(key)
+	return value, ok, nil
+}
+
+func (db *ReadMostly[V]) update(key string, fn func(value V, ok bool) (V, Op)) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	switch value, op := fn(db.TryGet(key)); op {
+	case OpAdd:
+		db.set(key, value)
+	case OpDel:
+		db.unset(key)
+	}
+	return nil
+}
+
+func (db *ReadMostly[V]) snapshot() (map[string]V, map[string]time.Time, error) {
+	return maps.Clone(*db.data.Load()), nil, nil
+}
+
+func (db *ReadMostly[V]) restore(data map[string]V, _ map[string]time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	published := maps.Clone(*db.data.Load())
+	maps.Copy(published, data)
+	db.data.Store(&published)
+	return nil
+}
+
+// set has to be called with the lock held.
+func (db *ReadMostly[V]) set(key string, value V) {
+	current := *db.data.Load()
+	if old, ok := current[key]; ok && old == value {
+		return
+	}
+	published := maps.Clone(current)
+	published[key] = value
+	db.data.Store(&published)
+}
+
+// unset has to be called with the lock held.
+func (db *ReadMostly[V]) unset(key string) {
+	current := *db.data.Load()
+	if _, ok := current[key]; !ok {
+		return
+	}
+	published := maps.Clone(current)
+	delete(published, key)
+	db.data.Store(&published)
+}
@@ -0,0 +1,122 @@
+package nanodb
+
+import "time"
+
+// Policy changes a runtime policy through Reconfigure.
+type Policy func(*policies)
+
+type policies struct {
+	timeout      *time.Duration
+	quotas       map[string]Quota
+	syncInterval *time.Duration
+	adaptive     *adaptiveTTL
+}
+
+// TTL changes the timeout, unlike the Timeout setter entries keep their insertion time.
+func TTL(timeout time.Duration) Policy {
+	return func(p *policies) {
+		p.timeout = &timeout
+	}
+}
+
+func Limit(prefix string, quota Quota) Policy {
+	return func(p *policies) {
+		if p.quotas == nil {
+			p.quotas = make(map[string]Quota)
+		}
+		p.quotas[prefix] = quota
+	}
+}
+
+// Sync changes the Relaxed mode sync interval, DB ignores it.
+func Sync(interval time.Duration) Policy {
+	return func(p *policies) {
+		p.syncInterval = &interval
+	}
+}
+
+// Adaptive changes the AdaptiveTTL bounds, DBCache ignores it.
+func Adaptive(min, max time.Duration) Policy {
+	return func(p *policies) {
+		p.adaptive = &adaptiveTTL{min: min, max: max}
+	}
+}
+
+func collectPolicies(changes []Policy) policies {
+	result := policies{}
+	for _, change := range changes {
+		change(&result)
+	}
+	return result
+}
+
+// Reconfigure applies every policy at once, readers never see half of them applied.
+// Data stays and pending expirations are rescheduled against the new policies.
+func (db *DB[T]) Reconfigure(changes ...Policy) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	p := collectPolicies(changes)
+	for prefix, quota := range p.quotas {
+		if db.quotas == nil {
+			db.quotas = make(map[string]Quota)
+		}
+		db.quotas[prefix] = quota
+		db.counters = nil
+	}
+	if p.adaptive != nil {
+		if db.access == nil {
+			db.access = newSketch(1024)
+		}
+		db.adaptive = p.adaptive
+	}
+	if p.timeout != nil {
+		db.timeout = *p.timeout
+	}
+	if p.timeout == nil && p.adaptive == nil {
+		return db
+	}
+
+	for key := range db.data {
+		if db.timeout == 0 {
+			db.expiries.cancel(key)
+			db.refreshes.cancel(key)
+			continue
+		}
+		db.scheduleDel(key)
+	}
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Reconfigure(changes ...Policy) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	p := collectPolicies(changes)
+	for prefix, quota := range p.quotas {
+		if db.quotas == nil {
+			db.quotas = make(map[string]Quota)
+		}
+		db.quotas[prefix] = quota
+		db.counters = nil
+	}
+	if p.syncInterval != nil {
+		db.syncer.interval = *p.syncInterval
+		if db.syncer.stop != nil {
+			close(db.syncer.stop)
+			db.syncer.stop = nil
+			db.startSyncer()
+		}
+	}
+	if p.timeout != nil {
+		db.timeout = *p.timeout
+		for key := range db.keys() {
+			if db.timeout == 0 {
+				db.expiries.cancel(key)
+				continue
+			}
+			db.scheduleDel(key)
+		}
+	}
+	return db
+}
@@ -0,0 +1,97 @@
+package nanodb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrReference = errors.New("nanodb: broken reference")
+
+type reference[T any] struct {
+	from  string
+	to    string
+	index *secondaryIndex[T]
+}
+
+// RequireRef makes every entry under the from prefix reference the entry under the to prefix
+// named by its field: Add of an entry whose referenced key is missing and Del of a referenced entry
+// are refused with an ErrReference, reported by Add and Del and returned by batches and the value helpers.
+// A zero field references nothing. The timeout and cleanup policies still remove referenced entries,
+// and entries stored before the call are not checked.
+func (db *DB[T]) RequireRef(from, field, to string) error {
+	typ := reflect.TypeFor[T]()
+	pointer := typ.Kind() == reflect.Pointer
+	if pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("nanodb: RequireRef on non-struct %s", typ)
+	}
+	structField, ok := typ.FieldByName(field)
+	if !ok || !structField.IsExported() {
+		return fmt.Errorf("nanodb: RequireRef on unknown field %s.%s", typ, field)
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	// The index holds the normalized referenced key, so ids meet keys however they are cased.
+	from, to = db.normalized(from), db.normalized(to)
+	index := db.newIndex(func(value T) any {
+		v := reflect.ValueOf(value)
+		if pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		if v = v.FieldByIndex(structField.Index); v.IsZero() {
+			return nil
+		}
+		return db.normalized(to + fmt.Sprint(v.Interface()))
+	})
+	for key, value := range db.data {
+		index.add(key, value)
+	}
+	db.refs = append(db.refs, reference[T]{from: from, to: to, index: index})
+	return nil
+}
+
+// checkRefs has to be called with the lock held, like checkUnique it checks the state after every op.
+func (db *DB[T]) checkRefs(ops ...batchOp[T]) error {
+	if len(db.refs) == 0 {
+		return nil
+	}
+	final := finalOps(ops)
+	exists := func(key string) bool {
+		if op, ok := final[key]; ok {
+			return !op.del
+		}
+		_, ok := db.data[key]
+		return ok
+	}
+
+	for _, ref := range db.refs {
+		for key, op := range final {
+			if op.del || !strings.HasPrefix(key, ref.from) {
+				continue
+			}
+			if target := ref.index.extract(op.value); target != nil && !exists(target.(string)) {
+				return fmt.Errorf("%w: %q references missing %q", ErrReference, key, target)
+			}
+		}
+		for key, op := range final {
+			if !op.del || !strings.HasPrefix(key, ref.to) {
+				continue
+			}
+			for holder := range ref.index.keys[key] {
+				if _, touched := final[holder]; !touched && strings.HasPrefix(holder, ref.from) {
+					return fmt.Errorf("%w: %q is referenced by %q", ErrReference, key, holder)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package nanodb
+
+import "time"
+
+type refresher[T any] struct {
+	fraction float64
+	refresh  func(key string, value T) (T, error)
+}
+
+// RefreshAhead reloads entries in the background once fraction of the timeout has elapsed,
+// a failed refresh or one refused by a quota, unique index or reference is logged and the entry is left to expire.
+func (db *DB[T]) RefreshAhead(fraction float64, refresh func(key string, value T) (T, error)) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.refresher = &refresher[T]{fraction: fraction, refresh: refresh}
+	return db
+}
+
+func (db *DB[T]) scheduleRefresh(key string) {
+	if db.refresher == nil || db.timeout == 0 || db.refresher.fraction <= 0 || db.refresher.fraction >= 1 {
+		return
+	}
+
+	lifetime, pool, refresh := db.lifetimes[key], db.workerPool(), db.refresher.refresh
+	ahead := time.Duration(float64(db.timeout) * db.refresher.fraction)
+	db.refreshes.schedule(key, ahead-time.Since(lifetime), func() {
+		pool.submit(func() { db.refresh(key, lifetime, refresh) })
+	})
+}
+
+// refresh replaces the value with refresh(key, value) unless the entry changed since lifetime.
+func (db *DB[T]) refresh(key string, lifetime time.Time, refresh func(key string, value T) (T, error)) {
+	db.mutex.RLock()
+	key = db.normalized(key)
+	value, ok := db.data[key]
+	current := db.lifetimes[key]
+	db.mutex.RUnlock()
+	if !ok || !current.Equal(lifetime) {
+		return
+	}
+
+	refreshed, err := refresh(key, value)
+	if err != nil {
+		db.report("refresh", key, err)
+		return
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if !db.lifetimes[key].Equal(lifetime) {
+		return
+	}
+	if err := db.admit(key, refreshed); err != nil {
+		db.report("refresh", key, err)
+		return
+	}
+	db.set(key, refreshed, time.Now())
+}
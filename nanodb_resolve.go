@@ -0,0 +1,47 @@
+package nanodb
+
+import (
+	"reflect"
+	"time"
+)
+
+type Versioned[T any] struct {
+	Value    T
+	Modified time.Time
+}
+
+// Resolver picks the value to keep when a merge finds a key holding different values on both sides.
+type Resolver[T any] func(key string, mine, theirs Versioned[T]) T
+
+// Resolve sets the resolver used by Migrate and ImportArchive, the incoming value wins without one.
+func (db *DB[T]) Resolve(resolver Resolver[T]) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.resolver = resolver
+	return db
+}
+
+// Resolve sets the resolver used when reloading a file changed by another process
+// and by Migrate and ImportArchive, the incoming value wins without one.
+func (db *DBCache[T, EncoderT, DecoderT]) Resolve(resolver Resolver[T]) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.resolver = resolver
+	return db
+}
+
+func resolve[T any](
+	resolver Resolver[T],
+	data map[string]T,
+	lifetimes map[string]time.Time,
+	key string,
+	theirs Versioned[T],
+) T {
+	mine, ok := data[key]
+	if resolver == nil || !ok || reflect.DeepEqual(mine, theirs.Value) {
+		return theirs.Value
+	}
+	return resolver(key, Versioned[T]{Value: mine, Modified: lifetimes[key]}, theirs)
+}
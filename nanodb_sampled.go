@@ -0,0 +1,46 @@
+package nanodb
+
+import "time"
+
+// sampledRounds bounds how many samples a single cycle takes when most of them keep turning out expired.
+const sampledRounds = 16
+
+type sampler struct {
+	samples int
+}
+
+// SampledExpiration replaces the per-key expiration timers with a cycle every interval
+// that checks up to samples random keys and deletes the expired ones, repeating while more than
+// a quarter of a sample was expired. Reads never return an expired entry still waiting to be sampled.
+func (db *DB[T]) SampledExpiration(interval time.Duration, samples int) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.sampler = &sampler{samples: max(samples, 1)}
+	for key := range db.lifetimes {
+		db.expiries.cancel(key)
+	}
+	db.schedules.every("expire", interval, db.expireSample)
+	return db
+}
+
+// expireSample has to be called with the lock held.
+func (db *DB[T]) expireSample() {
+	for range sampledRounds {
+		checked, expired := 0, 0
+		// Map iteration starts at a random position, which is what makes this a sample.
+		for key := range db.data {
+			if checked == db.sampler.samples {
+				break
+			}
+			checked++
+			if db.expired(key) {
+				db.evict(key)
+				expired++
+			}
+		}
+		if expired*4 <= checked {
+			return
+		}
+	}
+}
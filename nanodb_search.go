@@ -0,0 +1,101 @@
+package nanodb
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+type searchIndex[T any] struct {
+	tokenize func(value T) []string
+	postings map[string]map[string]struct{}
+	tokens   map[string][]string
+}
+
+// EnableSearch builds an inverted index over the tokens produced by tokenize,
+// kept up to date on every mutation. Tokens are matched case-insensitively.
+func (db *DB[T]) EnableSearch(tokenize func(value T) []string) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	index := &searchIndex[T]{
+		tokenize: tokenize,
+		postings: make(map[string]map[string]struct{}),
+		tokens:   make(map[string][]string),
+	}
+	for key, value := range db.data {
+		index.add(key, value)
+	}
+	if db.search == nil {
+		db.addObserver(func(changes []change[T]) {
+			for _, change := range changes {
+				db.search.remove(change.key)
+				if change.exists {
+					db.search.add(change.key, change.value)
+				}
+			}
+		})
+	}
+	db.search = index
+	return db
+}
+
+// Search returns the keys, sorted, whose values contain every word of the query.
+// Keys the guard refuses to read are left out.
+func (db *DB[T]) Search(query string) []string {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if db.search == nil {
+		return nil
+	}
+
+	var result []string
+	for i, word := range Words(query) {
+		keys := db.search.postings[word]
+		if i == 0 {
+			for key := range keys {
+				if db.allowed(OpGet, key) {
+					result = append(result, key)
+				}
+			}
+			continue
+		}
+		result = slices.DeleteFunc(result, func(key string) bool {
+			_, ok := keys[key]
+			return !ok
+		})
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Words splits text into lowercase words, it is a ready tokenizer for DB[string].
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func (index *searchIndex[T]) add(key string, value T) {
+	tokens := slices.Clone(index.tokenize(value))
+	for i, token := range tokens {
+		token = strings.ToLower(token)
+		tokens[i] = token
+		if index.postings[token] == nil {
+			index.postings[token] = make(map[string]struct{})
+		}
+		index.postings[token][key] = struct{}{}
+	}
+	index.tokens[key] = tokens
+}
+
+func (index *searchIndex[T]) remove(key string) {
+	for _, token := range index.tokens[key] {
+		delete(index.postings[token], key)
+		if len(index.postings[token]) == 0 {
+			delete(index.postings, token)
+		}
+	}
+	delete(index.tokens, key)
+}
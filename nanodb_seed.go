@@ -0,0 +1,88 @@
+package nanodb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"slices"
+	"time"
+)
+
+// WithSeed overlays the cache file on top of the defaults from a read-only seed file:
+// keys missing from the cache file are copied from the seed when the cache is opened.
+// The keys of the seed are recorded in a file next to the cache, named after it with ".seeded"
+// appended, so a default deleted from the cache stays deleted and only defaults added to the seed later are copied.
+func WithSeed(filename string) Option {
+	return func(opts *options) {
+		opts.seed = filename
+	}
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) applySeed() (err error) {
+	defer recoverPanic(db.panics, &err)
+
+	seed, err := db.fsys.Open(db.seed)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := seed.Close(); err == nil && e != nil {
+			err = e
+		}
+	}()
+
+	defaults := make(map[string]T)
+	if err := db.newDecoder(seed).Decode(&defaults); err != nil {
+		return err
+	}
+
+	seeded, err := db.readSeeded()
+	if err != nil {
+		return err
+	}
+	added, recorded := false, false
+	for key, value := range defaults {
+		if seeded[key] {
+			continue
+		}
+		seeded[key], recorded = true, true
+		if !db.has(key) {
+			db.set(key, value, time.Now())
+			added = true
+		}
+	}
+	if added {
+		if err := db.flush(); err != nil {
+			return err
+		}
+	}
+	if !recorded {
+		return nil
+	}
+	data, err := json.Marshal(slices.Sorted(maps.Keys(seeded)))
+	if err != nil {
+		return err
+	}
+	return writeFile(db.fsys, db.cache+".seeded", data)
+}
+
+// readSeeded has to be called with the lock held, a missing file counts as nothing seeded yet.
+func (db *DBCache[T, EncoderT, DecoderT]) readSeeded() (map[string]bool, error) {
+	seeded := make(map[string]bool)
+	data, err := fs.ReadFile(db.fsys, db.cache+".seeded")
+	if errors.Is(err, fs.ErrNotExist) {
+		return seeded, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		seeded[key] = true
+	}
+	return seeded, nil
+}
@@ -0,0 +1,19 @@
+package nanodb
+
+import "time"
+
+// Seen records an idempotency key for window and reports whether it was already recorded
+// within a window still open, checking and recording under a single write lock.
+// A key already seen is left as it is, its window is not extended and nothing is saved.
+// Entries hold the time their window closes, use a DBCache to remember keys across restarts
+// and a Timeout at least as long as the longest window to drop closed ones.
+func Seen(db Store[time.Time], key string, window time.Duration) (seen bool, err error) {
+	now := time.Now()
+	err = db.update(key, func(closes time.Time, ok bool) (time.Time, Op) {
+		if seen = ok && now.Before(closes); seen {
+			return closes, OpGet
+		}
+		return now.Add(window), OpAdd
+	})
+	return
+}
@@ -0,0 +1,90 @@
+package nanodb
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// Set is a sorted slice of unique members, use it with SAdd, SRem and friends.
+type Set[M cmp.Ordered] []M
+
+// MultiSet counts occurrences of members, use it with MAdd, MRem and MCount.
+type MultiSet[M cmp.Ordered] map[M]int
+
+// SAdd returns the number of members that were not in the set yet.
+func SAdd[M cmp.Ordered](db Store[Set[M]], key string, members ...M) (added int, err error) {
+	err = db.update(key, func(set Set[M], _ bool) (Set[M], Op) {
+		set = slices.Clone(set)
+		for _, member := range members {
+			if i, found := slices.BinarySearch(set, member); !found {
+				set = slices.Insert(set, i, member)
+				added++
+			}
+		}
+		return set, OpAdd
+	})
+	return
+}
+
+// SRem returns the number of members removed, the entry is deleted once the set is empty.
+func SRem[M cmp.Ordered](db Store[Set[M]], key string, members ...M) (removed int, err error) {
+	err = db.update(key, func(set Set[M], _ bool) (Set[M], Op) {
+		set = slices.Clone(set)
+		for _, member := range members {
+			if i, found := slices.BinarySearch(set, member); found {
+				set = slices.Delete(set, i, i+1)
+				removed++
+			}
+		}
+		return set, addOrDel(len(set) > 0)
+	})
+	return
+}
+
+func SMembers[M cmp.Ordered](db Store[Set[M]], key string) ([]M, error) {
+	set, _, err := db.read(key)
+	return slices.Clone(set), err
+}
+
+func SIsMember[M cmp.Ordered](db Store[Set[M]], key string, member M) (bool, error) {
+	set, _, err := db.read(key)
+	if err != nil {
+		return false, err
+	}
+	_, found := slices.BinarySearch(set, member)
+	return found, nil
+}
+
+func MAdd[M cmp.Ordered](db Store[MultiSet[M]], key string, members ...M) error {
+	return db.update(key, func(set MultiSet[M], _ bool) (MultiSet[M], Op) {
+		set = maps.Clone(set)
+		if set == nil {
+			set = MultiSet[M]{}
+		}
+		for _, member := range members {
+			set[member]++
+		}
+		return set, OpAdd
+	})
+}
+
+// MRem removes one occurrence of every member, members reaching zero are dropped.
+func MRem[M cmp.Ordered](db Store[MultiSet[M]], key string, members ...M) error {
+	return db.update(key, func(set MultiSet[M], _ bool) (MultiSet[M], Op) {
+		set = maps.Clone(set)
+		for _, member := range members {
+			if set[member] > 1 {
+				set[member]--
+			} else {
+				delete(set, member)
+			}
+		}
+		return set, addOrDel(len(set) > 0)
+	})
+}
+
+func MCount[M cmp.Ordered](db Store[MultiSet[M]], key string, member M) (int, error) {
+	set, _, err := db.read(key)
+	return set[member], err
+}
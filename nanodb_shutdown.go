@@ -0,0 +1,54 @@
+package nanodb
+
+import "context"
+
+// Shutdown stops pending expiration, refresh and cleanup timers and waits for running timers
+// and pool callbacks to finish, or for ctx to expire. The data stays readable and writable,
+// but entries no longer expire and callbacks no longer run. Pending NotifyExpired batches are flushed.
+func (db *DB[T]) Shutdown(ctx context.Context) error {
+	db.mutex.Lock()
+	for _, notifier := range db.notifiers {
+		db.flushExpired(notifier)
+	}
+	db.expiries.close()
+	db.refreshes.close()
+	db.schedules.close()
+	pool := db.pool
+	db.mutex.Unlock()
+
+	if err := db.expiries.wait(ctx); err != nil {
+		return err
+	}
+	if err := db.refreshes.wait(ctx); err != nil {
+		return err
+	}
+	if err := db.schedules.wait(ctx); err != nil {
+		return err
+	}
+	if pool != nil {
+		return pool.stop(ctx)
+	}
+	return nil
+}
+
+// Shutdown waits for an in-flight save, saves writes pending in Relaxed mode,
+// stops pending expiration and cleanup timers and waits for running ones.
+func (db *DBCache[T, EncoderT, DecoderT]) Shutdown(ctx context.Context) error {
+	db.mutex.Lock()
+	db.waitCommits()
+	db.expiries.close()
+	db.schedules.close()
+	err := db.stopSyncer()
+	db.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if err := db.expiries.wait(ctx); err != nil {
+		return err
+	}
+	if err := db.schedules.wait(ctx); err != nil {
+		return err
+	}
+	return waitContext(ctx, db.syncer.running)
+}
@@ -0,0 +1,66 @@
+package nanodb
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrSlow = errors.New("nanodb: slow operation")
+
+// WarnSlow reports every Get, Add, Del, update or batch taking at least threshold, lock wait
+// and save included, as an ErrSlow to the OnError handler, or as a slog warning without one.
+// A threshold of 0 turns it off.
+func (db *DB[T]) WarnSlow(threshold time.Duration) *DB[T] {
+	db.slow.Store(int64(threshold))
+	return db
+}
+
+// WarnSlow reports every Get, Add, Del, update or batch taking at least threshold, lock wait
+// and save included, as an ErrSlow to the OnError handler, or as a slog warning without one.
+// A threshold of 0 turns it off.
+func (db *DBCache[T, EncoderT, DecoderT]) WarnSlow(threshold time.Duration) *DBCache[T, EncoderT, DecoderT] {
+	db.slow.Store(int64(threshold))
+	return db
+}
+
+// traceSlow is called before taking the lock, the returned func reports op once it finished.
+func (db *DB[T]) traceSlow(op string, key string) func() {
+	return traceSlow(time.Duration(db.slow.Load()), func(elapsed time.Duration) {
+		db.mutex.RLock()
+		handler := db.onError
+		db.mutex.RUnlock()
+		warnSlow(handler, "nanodb", op, key, elapsed)
+	})
+}
+
+// traceSlow is called before taking the lock, the returned func reports op once it finished.
+func (db *DBCache[T, EncoderT, DecoderT]) traceSlow(op string, key string) func() {
+	return traceSlow(time.Duration(db.slow.Load()), func(elapsed time.Duration) {
+		db.mutex.Lock()
+		handler := db.onError
+		db.mutex.Unlock()
+		warnSlow(handler, "nanodb-cache", op, key, elapsed)
+	})
+}
+
+func traceSlow(threshold time.Duration, warn func(elapsed time.Duration)) func() {
+	if threshold <= 0 {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		if elapsed := time.Since(start); elapsed >= threshold {
+			warn(elapsed)
+		}
+	}
+}
+
+func warnSlow(handler ErrorHandler, source string, op string, key string, elapsed time.Duration) {
+	if handler == nil {
+		slog.Warn(source, op, key, "elapsed", elapsed)
+		return
+	}
+	handler(op, key, fmt.Errorf("%w: %s took %s", ErrSlow, op, elapsed))
+}
@@ -0,0 +1,92 @@
+package nanodb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var ErrTransition = errors.New("nanodb: transition not allowed")
+
+type State string
+
+// Transitions lists the states every state may move to, e.g. {"pending": {"running"}, "running": {"done", "failed"}}.
+type Transitions map[State][]State
+
+// Transition moves the entry under key from one state to another under the write lock, so of two
+// racing callers only one succeeds. The state is the string field of struct T, or of the struct
+// T points to, tagged `nanodb:"state"`. A missing entry, an entry in another state than from or
+// a move missing from transitions fail with ErrTransition and leave the store untouched:
+// no write, no renewed lifetime, no observers and no save.
+func Transition[T any](db Store[T], transitions Transitions, key string, from, to State) error {
+	field, err := stateField[T]()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(transitions[from], to) {
+		return fmt.Errorf("%w: %q to %q", ErrTransition, from, to)
+	}
+
+	var refused error
+	err = db.update(key, func(value T, ok bool) (T, Op) {
+		if !ok {
+			refused = fmt.Errorf("%w: %q is missing", ErrTransition, key)
+			return value, OpGet
+		}
+		if current := State(stateOf(value, field).String()); current != from {
+			refused = fmt.Errorf("%w: %q is %q, not %q", ErrTransition, key, current, from)
+			return value, OpGet
+		}
+		return withState(value, field, to), OpAdd
+	})
+	if refused != nil {
+		return refused
+	}
+	return err
+}
+
+// stateField finds the index of the field tagged `nanodb:"state"`.
+func stateField[T any]() (int, error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Struct {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			tagged := slices.Contains(strings.Split(field.Tag.Get("nanodb"), ","), "state")
+			if tagged && field.IsExported() && field.Type.Kind() == reflect.String {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: %s has no string field tagged `nanodb:\"state\"`", ErrTransition, reflect.TypeFor[T]())
+}
+
+func stateOf[T any](value T, field int) reflect.Value {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.ValueOf("")
+		}
+		v = v.Elem()
+	}
+	return v.Field(field)
+}
+
+// withState returns value with the state replaced, a pointed to struct is copied rather than changed in place.
+func withState[T any](value T, field int, state State) T {
+	v := reflect.ValueOf(&value).Elem()
+	if v.Kind() == reflect.Pointer {
+		copied := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			copied.Elem().Set(v.Elem())
+		}
+		v.Set(copied)
+		v = copied.Elem()
+	}
+	v.Field(field).SetString(string(state))
+	return value
+}
@@ -0,0 +1,77 @@
+package nanodb
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+type Stats struct {
+	Len      int
+	Hits     uint64
+	Misses   uint64
+	LastSave time.Time
+}
+
+// HitRate is the share of lookups that found their key, 0 before the first lookup.
+func (stats Stats) HitRate() float64 {
+	if stats.Hits+stats.Misses == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+}
+
+func (stats Stats) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("len", stats.Len),
+		slog.Uint64("hits", stats.Hits),
+		slog.Uint64("misses", stats.Misses),
+		slog.Float64("hit_rate", stats.HitRate()),
+	}
+	if !stats.LastSave.IsZero() {
+		attrs = append(attrs, slog.Time("last_save", stats.LastSave))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (db *DB[T]) Stats() Stats {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.stats.snapshot(len(db.data))
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Stats() Stats {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.stats.snapshot(len(db.data) + len(db.raw))
+}
+
+// LogValue summarises the cache health without its keys or values, it never blocks on a busy DBCache.
+func (db *DBCache[T, EncoderT, DecoderT]) LogValue() slog.Value {
+	if !db.mutex.TryLock() {
+		return slog.GroupValue(slog.Bool("busy", true))
+	}
+	defer db.mutex.Unlock()
+
+	return db.stats.snapshot(len(db.data) + len(db.raw)).LogValue()
+}
+
+type stats struct {
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+	lastSave time.Time
+}
+
+func (stats *stats) lookup(hit bool) {
+	if hit {
+		stats.hits.Add(1)
+	} else {
+		stats.misses.Add(1)
+	}
+}
+
+func (stats *stats) snapshot(len int) Stats {
+	return Stats{Len: len, Hits: stats.hits.Load(), Misses: stats.misses.Load(), LastSave: stats.lastSave}
+}
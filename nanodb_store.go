@@ -0,0 +1,141 @@
+package nanodb
+
+import (
+	"iter"
+	"time"
+)
+
+// Store is implemented by both DB and DBCache, the value helpers (sorted sets, sets, ...) accept either.
+type Store[T any] interface {
+	Seq2() iter.Seq2[string, T]
+	read(key string) (T, bool, error)
+	update(key string, fn func(value T, ok bool) (T, Op)) error
+	snapshot() (map[string]T, map[string]time.Time, error)
+	restore(data map[string]T, lifetimes map[string]time.Time) error
+}
+
+var (
+	_ Store[int] = (*DB[int])(nil)
+	_ Store[int] = (*DBCache[int, Encoder, Decoder])(nil)
+)
+
+// Update replaces the value with fn(value) under the write lock, a missing key starts from the zero value.
+// Refused writes are reported as "update".
+func (db *DB[T]) Update(key string, fn func(value T) T) *DB[T] {
+	if err := db.update(key, func(value T, _ bool) (T, Op) { return fn(value), OpAdd }); err != nil {
+		db.report("update", key, err)
+	}
+	return db
+}
+
+// Update replaces the value with fn(value) under the lock and saves once.
+func (db *DBCache[T, EncoderT, DecoderT]) Update(key string, fn func(value T) T) error {
+	return db.update(key, func(value T, _ bool) (T, Op) { return fn(value), OpAdd })
+}
+
+func (db *DB[T]) read(key string) (T, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpGet, key); err != nil {
+		var zero T
+		return zero, false, err
+	}
+	value, ok := db.data[key]
+	return value, ok, nil
+}
+
+// update applies fn under the write lock: OpAdd stores the value fn returns, OpDel deletes the entry
+// and OpGet leaves it untouched, nothing is written, observed or saved.
+func (db *DB[T]) update(key string, fn func(value T, ok bool) (T, Op)) error {
+	defer db.traceSlow("update", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpAdd, key); err != nil {
+		return err
+	}
+
+	value, op, err := apply(db.panics, db.data, key, fn)
+	if err != nil || op == OpGet {
+		return err
+	}
+	if op == OpDel {
+		if err := db.check(OpDel, key); err != nil {
+			return err
+		}
+		if err := db.checkRefs(batchOp[T]{key: key, del: true}); err != nil {
+			return err
+		}
+		db.unset(key)
+		return nil
+	}
+	if err := db.admit(key, value); err != nil {
+		return err
+	}
+
+	db.set(key, value, time.Now())
+	return nil
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) read(key string) (T, bool, error) {
+	return db.TryGet(key)
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) update(key string, fn func(value T, ok bool) (T, Op)) error {
+	defer db.traceSlow("update", key)()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key = db.normalized(key)
+	if err := db.check(OpAdd, key); err != nil {
+		return err
+	}
+	if err := db.throttle(); err != nil {
+		return err
+	}
+	if err := db.loadForWrite(); err != nil {
+		return err
+	}
+	if err := db.decodeKey(key); err != nil {
+		return err
+	}
+
+	value, op, err := apply(db.panics, db.data, key, fn)
+	if err != nil || op == OpGet {
+		return err
+	}
+	if op == OpDel {
+		if err := db.check(OpDel, key); err != nil {
+			return err
+		}
+		db.unset(key)
+		return db.save()
+	}
+	if err := db.loadQuotas(); err != nil {
+		return err
+	}
+	if err := checkQuotas(db.quotas, db.quotaUsage(), key, value, db.size); err != nil {
+		return err
+	}
+
+	db.set(key, value, time.Now())
+	return db.save()
+}
+
+func apply[T any](policy PanicPolicy, data map[string]T, key string, fn func(value T, ok bool) (T, Op)) (value T, op Op, err error) {
+	defer recoverPanic(policy, &err)
+	value, ok := data[key]
+	value, op = fn(value, ok)
+	return value, op, nil
+}
+
+// addOrDel is the update result storing the value while keep holds and deleting the entry otherwise.
+func addOrDel(keep bool) Op {
+	if keep {
+		return OpAdd
+	}
+	return OpDel
+}
@@ -1,14 +1,30 @@
 package nanodb
 
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math"
 	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -63,122 +79,3180 @@ type TestingUser struct {
 	Name string `json:"name"`
 }
 
+// copyTestdata copies a testdata file into a temporary directory and returns the copy's path.
+func copyTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
 func TestNanodbCache(t *testing.T) {
-	if err := exec.Command("cp", "testdata/cache.json", "testdata/cache_test.json").Run(); err != nil {
+	filename := copyTestdata(t, "cache.json")
+	db, err := From[*TestingUser](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, key := range testKeys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Add(key, &TestingUser{100 + i, key}); err != nil {
+				panic(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range testKeys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Del(key); err != nil {
+				panic(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	original := map[string]*TestingUser{}
+	originalData, _ := os.ReadFile("testdata/cache.json")
+	if err := json.Unmarshal(originalData, &original); err != nil {
+		t.Fatal(err)
+	}
+	afterTest := map[string]*TestingUser{}
+	afterData, _ := os.ReadFile(filename)
+	if err := json.Unmarshal(afterData, &afterTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(original) != len(afterTest) {
+		t.Errorf("len(original) != len(afterTest)")
+	}
+	for key, value := range original {
+		if !reflect.DeepEqual(afterTest[key], value) {
+			t.Errorf("afterTest[%q] != %q (%q)", key, value, afterTest[key])
+		}
+	}
+
+	for range 2 {
+		for _, value := range db.Seq2() {
+			if slices.Contains(testKeys, value.Name) {
+				t.Fatal("unexpected value in cache")
+			}
+		}
+	}
+}
+
+func TestDB_Timeout(t *testing.T) {
+	db := New[string]().Timeout(time.Millisecond * 100)
+
+	db.Add("hello", "world")
+	for _, key := range testKeys {
+		db.Add(key, key)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	db.Add("hello", "new_world")
+	time.Sleep(time.Millisecond * 60)
+
+	if db.Len() != 1 {
+		t.Errorf("db.Len() != 1")
+	}
+	if db.Get("hello") != "new_world" {
+		t.Errorf("db.Get('hello') != \"new_world\"")
+	}
+}
+
+func TestDBCache_Timeout(t *testing.T) {
+	filename := copyTestdata(t, "cache.json")
+	db, err := From[*TestingUser](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Timeout(time.Millisecond * 100)
+	time.Sleep(time.Millisecond * 50)
+	if err := db.Add("hello", &TestingUser{0, "new_world"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 60)
+
+	dblen, err := db.Len()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dblen != 1 {
+		t.Errorf("db.Len() != 1 (%d)", dblen)
+	}
+
+	if val, _ := db.Get("hello"); val.Name != "new_world" {
+		t.Errorf("db.Get('hello') != \"new_world\"")
+	}
+
+	snapshot, _ := db.KeysSnapshot()
+	if len(snapshot) != 1 && snapshot[0] != "hello" {
+		t.Errorf("db.KeysSnapshot() != \"hello\"")
+	}
+}
+
+func TestDB_Guard(t *testing.T) {
+	errDenied := errors.New("denied")
+	db := New[string]().Guard(func(op Op, key string) error {
+		if strings.HasPrefix(key, "private/") && op != OpGet {
+			return errDenied
+		}
+		if key == "private/secret" {
+			return errDenied
+		}
+		return nil
+	})
+
+	db.Add("public/hello", "world")
+	db.Add("private/hello", "world")
+	if _, ok := db.TryGet("private/hello"); ok {
+		t.Errorf("db.Add('private/hello') was not denied")
+	}
+	if db.Get("public/hello") != "world" {
+		t.Errorf("db.Get('public/hello') != \"world\"")
+	}
+
+	db.Guard(nil).Add("private/secret", "value")
+	db.Guard(func(op Op, key string) error {
+		if key == "private/secret" {
+			return errDenied
+		}
+		return nil
+	})
+	if value := db.Get("private/secret"); value != "" {
+		t.Errorf("db.Get('private/secret') != \"\" (%q)", value)
+	}
+	if keys := db.KeysSnapshot(); len(keys) != 1 || keys[0] != "public/hello" {
+		t.Errorf("db.KeysSnapshot() != [\"public/hello\"] (%q)", keys)
+	}
+	if db.Len() != 2 {
+		t.Errorf("db.Len() != 2")
+	}
+}
+
+func TestDBCache_Guard(t *testing.T) {
+	errDenied := errors.New("denied")
+	db, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Guard(func(op Op, key string) error {
+		if op == OpDel {
+			return errDenied
+		}
+		return nil
+	})
+
+	if err := db.Add("hello", "world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Del("hello"); !errors.Is(err, errDenied) {
+		t.Errorf("db.Del('hello') != errDenied (%v)", err)
+	}
+	if value, _ := db.Get("hello"); value != "world" {
+		t.Errorf("db.Get('hello') != \"world\"")
+	}
+}
+
+func TestDB_Quota(t *testing.T) {
+	db := New[string]().Quota("tenant/a/", Quota{MaxEntries: 2})
+
+	db.Add("tenant/a/1", "one").Add("tenant/a/2", "two").Add("tenant/a/3", "three")
+	db.Add("tenant/b/1", "one").Add("tenant/b/2", "two").Add("tenant/b/3", "three")
+	db.Add("tenant/a/2", "second")
+
+	if usage := db.Usage("tenant/a/"); usage.Entries != 2 {
+		t.Errorf("db.Usage('tenant/a/').Entries != 2 (%d)", usage.Entries)
+	}
+	if usage := db.Usage("tenant/b/"); usage.Entries != 3 {
+		t.Errorf("db.Usage('tenant/b/').Entries != 3 (%d)", usage.Entries)
+	}
+	if db.Get("tenant/a/2") != "second" {
+		t.Errorf("db.Get('tenant/a/2') != \"second\"")
+	}
+
+	quotaErr := &QuotaError{}
+	if err := db.TryAdd("tenant/a/3", "three"); !errors.As(err, &quotaErr) || quotaErr.Usage.Entries != 3 {
+		t.Errorf("db.TryAdd('tenant/a/3') != *QuotaError (%v)", err)
+	}
+	db.Del("tenant/a/1")
+	if err := db.TryAdd("tenant/a/3", "three"); err != nil {
+		t.Errorf("deleted entry still counted (%v)", err)
+	}
+	if usage, scanned := db.Usage("tenant/a/"), usageOf(db.data, "tenant/a/", jsonSize[string]); usage != scanned {
+		t.Errorf("running usage %+v != scanned %+v", usage, scanned)
+	}
+}
+
+func TestDBCache_Quota(t *testing.T) {
+	db, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Quota("tenant/", Quota{MaxBytes: 64})
+
+	if err := db.Add("tenant/small", "value"); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Add("tenant/large", strings.Repeat("x", 64))
+	quotaErr := &QuotaError{}
+	if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("db.Add('tenant/large') != *QuotaError (%v)", err)
+	}
+	if quotaErr.Prefix != "tenant/" {
+		t.Errorf("quotaErr.Prefix != \"tenant/\" (%q)", quotaErr.Prefix)
+	}
+
+	usage, err := db.Usage("tenant/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Entries != 1 || usage.Bytes == 0 {
+		t.Errorf("db.Usage('tenant/') != {1, >0} (%+v)", usage)
+	}
+
+	if err := db.Batch().Del("tenant/small").Add("tenant/large", strings.Repeat("x", 40)).Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := db.Usage("tenant/"); usage != usageOf(db.data, "tenant/", db.size) {
+		t.Errorf("running usage %+v != scanned %+v", usage, usageOf(db.data, "tenant/", db.size))
+	}
+}
+
+func TestDBCache_WithHMAC(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	db, err := From[string](filename, WithHMAC([]byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add("role", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := From[string](filename, WithHMAC([]byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := reopened.Get("role"); value != "user" {
+		t.Errorf("reopened.Get('role') != \"user\" (%q)", value)
+	}
+	if _, err := From[string](filename, WithHMAC([]byte("other"))); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("From(other key) != ErrIntegrity (%v)", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(data), "user", "root", 1)
+	if err := os.WriteFile(filename, []byte(tampered), 0666); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Second)
+	if err := os.Chtimes(filename, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get("role"); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("db.Get('role') != ErrIntegrity (%v)", err)
+	}
+	if _, err := db.Get("role"); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("second db.Get('role') != ErrIntegrity (%v)", err)
+	}
+}
+
+func TestDB_Archive(t *testing.T) {
+	db := New[*TestingUser]().Timeout(time.Hour)
+	db.Add("@green", &TestingUser{1, "John"}).Add("@red", &TestingUser{2, "Doe"})
+
+	archive := &bytes.Buffer{}
+	if err := db.ExportArchive(archive); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := From[*TestingUser](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.ImportArchive(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := cache.Get("@red"); user == nil || user.Name != "Doe" {
+		t.Errorf("cache.Get('@red') != Doe (%v)", user)
+	}
+
+	restored := New[*TestingUser]().Timeout(time.Hour)
+	if err := restored.ImportArchive(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.lifetimes["@green"].Equal(db.lifetimes["@green"]) {
+		t.Errorf("restored lifetime != original (%v != %v)", restored.lifetimes["@green"], db.lifetimes["@green"])
+	}
+
+	if err := restored.ImportArchive(strings.NewReader("not an archive")); err == nil {
+		t.Errorf("ImportArchive(garbage) == nil")
+	}
+
+	db.Guard(func(op Op, key string) error {
+		if key == "@red" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	guarded := &bytes.Buffer{}
+	if err := db.ExportArchive(guarded); err != nil {
+		t.Fatal(err)
+	}
+	filtered := New[*TestingUser]()
+	if err := filtered.ImportArchive(guarded); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := filtered.TryGet("@red"); ok || filtered.Len() != 1 {
+		t.Errorf("ExportArchive wrote a key the guard hides")
+	}
+
+	denied := New[*TestingUser]().Guard(func(op Op, key string) error {
+		if op == OpAdd && key == "@red" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	if err := denied.ImportArchive(bytes.NewReader(archive.Bytes())); err == nil || denied.Len() != 0 {
+		t.Errorf("ImportArchive wrote past the guard (%v, %d entries)", err, denied.Len())
+	}
+	limited, err := From[*TestingUser](filepath.Join(t.TempDir(), "limited.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited.Quota("@", Quota{MaxEntries: 1})
+	quotaErr := &QuotaError{}
+	if err := limited.ImportArchive(bytes.NewReader(archive.Bytes())); !errors.As(err, &quotaErr) {
+		t.Errorf("ImportArchive past the quota = %v", err)
+	}
+}
+
+func TestDB_ExportAnonymized(t *testing.T) {
+	db := New[*TestingUser]()
+	db.Add("user:1/email", &TestingUser{1, "john@example.com"})
+	db.Add("user:1/name", &TestingUser{1, "John"})
+	db.Add("user:2/name", &TestingUser{2, "Doe"})
+
+	dump := &bytes.Buffer{}
+	err := db.ExportAnonymized(dump, []byte("salt"), func(key string, value *TestingUser) any {
+		return &TestingUser{value.Id, "redacted"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(dump.String(), "John") || strings.Contains(dump.String(), "user") {
+		t.Errorf("dump leaks original data: %s", dump.String())
+	}
+
+	anonymized := map[string]*TestingUser{}
+	if err := json.Unmarshal(dump.Bytes(), &anonymized); err != nil {
+		t.Fatal(err)
+	}
+	prefix := AnonymizeKey([]byte("salt"), "user:1/")
+	matched := 0
+	for key, value := range anonymized {
+		if strings.HasPrefix(key, prefix) {
+			matched++
+		}
+		if value.Name != "redacted" {
+			t.Errorf("value.Name != \"redacted\" (%q)", value.Name)
+		}
+	}
+	if matched != 2 {
+		t.Errorf("keys with prefix user:1/ != 2 (%d)", matched)
+	}
+
+	db.Guard(func(op Op, key string) error {
+		if op == OpGet && strings.HasPrefix(key, "user:1/") {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	dump.Reset()
+	if err := db.ExportAnonymized(dump, []byte("salt"), func(key string, value *TestingUser) any { return value }); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(dump.String(), prefix) || strings.Contains(dump.String(), "John") {
+		t.Errorf("ExportAnonymized wrote keys the guard hides: %s", dump.String())
+	}
+}
+
+func TestDB_RefreshAhead(t *testing.T) {
+	refreshed := make(chan string, 16)
+	db := New[int]().Timeout(time.Millisecond*100).RefreshAhead(0.5, func(key string, value int) (int, error) {
+		refreshed <- key
+		if key == "cold" {
+			return 0, errors.New("no longer needed")
+		}
+		return value + 1, nil
+	})
+	db.Add("hot", 1).Add("cold", 1)
+
+	time.Sleep(time.Millisecond * 180)
+	if value, ok := db.TryGet("hot"); !ok || value < 3 {
+		t.Errorf("db.TryGet('hot') != (>=3, true) (%d, %v)", value, ok)
+	}
+	if _, ok := db.TryGet("cold"); ok {
+		t.Errorf("db.TryGet('cold') was refreshed")
+	}
+	if len(refreshed) < 3 {
+		t.Errorf("refreshes < 3 (%d)", len(refreshed))
+	}
+}
+
+func TestSortedSet(t *testing.T) {
+	db := New[SortedSet]()
+	cache, err := From[SortedSet](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []Store[SortedSet]{db, cache} {
+		for member, score := range map[string]float64{"alice": 30, "bob": 10, "carol": 20} {
+			if err := ZAdd(store, "board", member, score); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if score, err := ZIncrBy(store, "board", "bob", 25); err != nil || score != 35 {
+			t.Errorf("ZIncrBy('bob', 25) != 35 (%v, %v)", score, err)
+		}
+
+		top, err := ZRange(store, "board", -2, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(top) != 2 || top[0].Member != "alice" || top[1].Member != "bob" {
+			t.Errorf("ZRange(-2, -1) != [alice bob] (%+v)", top)
+		}
+		if rank, ok, _ := ZRank(store, "board", "carol"); !ok || rank != 0 {
+			t.Errorf("ZRank('carol') != 0 (%d, %v)", rank, ok)
+		}
+
+		if err := ZRem(store, "board", "carol"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := ZScore(store, "board", "carol"); ok {
+			t.Errorf("ZScore('carol') after ZRem is present")
+		}
+	}
+
+	reopened, err := From[SortedSet](cache.cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := ZRange(reopened, "board", 0, -1); len(all) != 2 {
+		t.Errorf("persisted ZRange(0, -1) != 2 members (%+v)", all)
+	}
+}
+
+func TestSet(t *testing.T) {
+	db := New[Set[string]]()
+
+	if added, err := SAdd(db, "tags", "go", "db", "go", "cache"); err != nil || added != 3 {
+		t.Errorf("SAdd != 3 (%d, %v)", added, err)
+	}
+	if members, _ := SMembers(db, "tags"); !slices.Equal(members, []string{"cache", "db", "go"}) {
+		t.Errorf("SMembers != [cache db go] (%q)", members)
+	}
+	if ok, _ := SIsMember(db, "tags", "db"); !ok {
+		t.Errorf("SIsMember('db') != true")
+	}
+	if removed, _ := SRem(db, "tags", "db", "missing"); removed != 1 {
+		t.Errorf("SRem != 1 (%d)", removed)
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, key := range testKeys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = SAdd(db, "colors", key)
+		}()
+	}
+	wg.Wait()
+	unique := slices.Clone(testKeys)
+	slices.Sort(unique)
+	if members, _ := SMembers(db, "colors"); !slices.Equal(members, slices.Compact(unique)) {
+		t.Errorf("concurrent SAdd lost members (%d != %d)", len(members), len(slices.Compact(unique)))
+	}
+
+	multi := New[MultiSet[int]]()
+	_ = MAdd(multi, "dice", 6, 6, 1)
+	_ = MRem(multi, "dice", 6, 1)
+	if count, _ := MCount(multi, "dice", 6); count != 1 {
+		t.Errorf("MCount(6) != 1 (%d)", count)
+	}
+	if count, _ := MCount(multi, "dice", 1); count != 0 {
+		t.Errorf("MCount(1) != 0 (%d)", count)
+	}
+}
+
+func TestHash(t *testing.T) {
+	db, err := From[map[string]int](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, field := range testKeys[:32] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := HSet(db, "user:1", field, i); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hash, _ := HGetAll(db, "user:1"); len(hash) != 32 {
+		t.Errorf("len(HGetAll('user:1')) != 32 (%d)", len(hash))
+	}
+	if value, ok, _ := HGet(db, "user:1", testKeys[3]); !ok || value != 3 {
+		t.Errorf("HGet(%q) != 3 (%d, %v)", testKeys[3], value, ok)
+	}
+	if err := HDel(db, "user:1", testKeys[:32]...); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.TryGet("user:1"); ok {
+		t.Errorf("'user:1' still present after deleting every field")
+	}
+}
+
+func TestBitmap(t *testing.T) {
+	db, err := From[[]byte](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []uint{1, 7, 8, 100} {
+		if _, err := SetBit(db, "active:monday", user, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, user := range []uint{7, 100} {
+		if _, err := SetBit(db, "active:tuesday", user, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if previous, _ := SetBit(db, "active:monday", 8, false); !previous {
+		t.Errorf("SetBit(8, false) previous != true")
+	}
+	if count, _ := CountBits(db, "active:monday"); count != 3 {
+		t.Errorf("CountBits('active:monday') != 3 (%d)", count)
+	}
+
+	if err := AndNot(db, "churned", "active:monday", "active:tuesday"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := CountBits(db, "churned"); count != 1 {
+		t.Errorf("CountBits('churned') != 1 (%d)", count)
+	}
+	if ok, _ := GetBit(db, "churned", 1); !ok {
+		t.Errorf("GetBit('churned', 1) != true")
+	}
+	if ok, _ := GetBit(db, "churned", 10000); ok {
+		t.Errorf("GetBit('churned', 10000) != false")
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	db := New[HyperLogLog]()
+
+	for i := range 10000 {
+		if _, err := PFAdd(db, "ips:monday", fmt.Sprintf("10.0.%d.%d", i/256, i%256)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 5000; i < 15000; i++ {
+		if _, err := PFAdd(db, "ips:tuesday", fmt.Sprintf("10.0.%d.%d", i/256, i%256)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changed, _ := PFAdd(db, "ips:monday", "10.0.0.1"); changed {
+		t.Errorf("PFAdd of a seen element changed the estimate")
+	}
+
+	approx := func(got uint64, want float64) bool {
+		return math.Abs(float64(got)-want)/want < 0.05
+	}
+	if count, _ := PFCount(db, "ips:monday"); !approx(count, 10000) {
+		t.Errorf("PFCount('ips:monday') !~ 10000 (%d)", count)
+	}
+	if err := PFMerge(db, "ips:week", "ips:monday", "ips:tuesday"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := PFCount(db, "ips:week"); !approx(count, 15000) {
+		t.Errorf("PFCount('ips:week') !~ 15000 (%d)", count)
+	}
+	if count, _ := PFCount(db, "missing"); count != 0 {
+		t.Errorf("PFCount('missing') != 0 (%d)", count)
+	}
+}
+
+func TestGeo(t *testing.T) {
+	db := New[GeoPoint]()
+	stores := map[string][2]float64{
+		"store:louvre":     {48.8606, 2.3376},
+		"store:notre-dame": {48.8530, 2.3499},
+		"store:versailles": {48.8049, 2.1204},
+		"store:london":     {51.5074, -0.1278},
+	}
+	for key, point := range stores {
+		if err := GeoAdd(db, key, point[0], point[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if near := Near(db, 48.8584, 2.2945, 5000); !slices.Equal(near, []string{"store:louvre", "store:notre-dame"}) {
+		t.Errorf("Near(eiffel, 5km) != [louvre notre-dame] (%q)", near)
+	}
+	if near := Near(db, 48.8584, 2.2945, 500_000); len(near) != 4 || near[3] != "store:london" {
+		t.Errorf("Near(eiffel, 500km) != 4 stores ending with london (%q)", near)
+	}
+	if distance := GeoDistance(48.8584, 2.2945, 51.5074, -0.1278); math.Abs(distance-340_000) > 5_000 {
+		t.Errorf("GeoDistance(paris, london) !~ 340km (%.0f)", distance)
+	}
+	if hash := Geohash(57.64911, 10.40744, 11); hash != "u4pruydqqvj" {
+		t.Errorf("Geohash() != u4pruydqqvj (%s)", hash)
+	}
+
+	if near := Near(db, 0, 0, 10_000_000); len(near) != 4 {
+		t.Errorf("Near(0 0, 10000km) != 4 stores (%q)", near)
+	}
+	if err := GeoAdd(db, "store:fiji", -16.5, 179.999); err != nil {
+		t.Fatal(err)
+	}
+	if near := Near(db, -16.5, -179.999, 1000); !slices.Equal(near, []string{"store:fiji"}) {
+		t.Errorf("Near across the dateline != [fiji] (%q)", near)
+	}
+	db.Del("store:fiji")
+	if near := Near(db, -16.5, 179.999, 1000); len(near) != 0 {
+		t.Errorf("deleted store still indexed (%q)", near)
+	}
+}
+
+func TestDBCache_Near(t *testing.T) {
+	db, err := From[GeoPoint](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GeoAdd(db, "store:louvre", 48.8606, 2.3376); err != nil {
+		t.Fatal(err)
+	}
+	if near := Near(db, 48.8584, 2.2945, 5000); !slices.Equal(near, []string{"store:louvre"}) {
+		t.Errorf("Near(eiffel, 5km) != [louvre] (%q)", near)
+	}
+	if err := GeoAdd(db, "store:louvre", 51.5074, -0.1278); err != nil {
+		t.Fatal(err)
+	}
+	if near := Near(db, 48.8584, 2.2945, 5000); len(near) != 0 {
+		t.Errorf("moved store still indexed at its old cell (%q)", near)
+	}
+	if near := Near(db, 51.5, -0.13, 5000); !slices.Equal(near, []string{"store:louvre"}) {
+		t.Errorf("moved store not found at its new cell (%q)", near)
+	}
+}
+
+func TestDB_Search(t *testing.T) {
+	db := New[string]().Timeout(time.Millisecond * 50)
+	db.Add("doc:1", "The quick brown fox")
+	db.EnableSearch(Words)
+	db.Add("doc:2", "A quick brown dog").Add("doc:3", "Lazy dogs sleep")
+
+	if keys := db.Search("QUICK brown"); !slices.Equal(keys, []string{"doc:1", "doc:2"}) {
+		t.Errorf("db.Search('QUICK brown') != [doc:1 doc:2] (%q)", keys)
+	}
+	db.Add("doc:1", "a slow red fox").Del("doc:2")
+	if keys := db.Search("quick"); len(keys) != 0 {
+		t.Errorf("db.Search('quick') after updates != [] (%q)", keys)
+	}
+	if keys := db.Search("fox"); !slices.Equal(keys, []string{"doc:1"}) {
+		t.Errorf("db.Search('fox') != [doc:1] (%q)", keys)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	if keys := db.Search("lazy"); len(keys) != 0 {
+		t.Errorf("db.Search('lazy') after expiry != [] (%q)", keys)
+	}
+
+	guarded := New[string]().EnableSearch(Words).Guard(func(op Op, key string) error {
+		if op == OpGet && strings.HasPrefix(key, "secret:") {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	guarded.Add("doc:1", "red fox").Add("secret:1", "red panda")
+	if keys := guarded.Search("red"); !slices.Equal(keys, []string{"doc:1"}) {
+		t.Errorf("guarded.Search('red') != [doc:1] (%q)", keys)
+	}
+}
+
+func TestDB_Query(t *testing.T) {
+	type Job struct {
+		Status string   `json:"status"`
+		Tags   []string `json:"tags"`
+	}
+	db := New[Job]()
+	db.Add("job:1", Job{"failed", []string{"urgent"}})
+	db.Add("job:2", Job{"done", nil})
+	db.Add("job:3", Job{"failed", []string{"nightly", "urgent"}})
+
+	failed, err := db.Query(".status", func(status any) bool { return status == "failed" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(failed, []string{"job:1", "job:3"}) {
+		t.Errorf("db.Query('.status' == failed) != [job:1 job:3] (%q)", failed)
+	}
+
+	urgent, _ := db.Query(".tags[1]", func(tag any) bool { return tag == "urgent" })
+	if !slices.Equal(urgent, []string{"job:3"}) {
+		t.Errorf("db.Query('.tags[1]' == urgent) != [job:3] (%q)", urgent)
+	}
+	all, _ := db.Query(".", func(any) bool { return true })
+	if len(all) != 3 {
+		t.Errorf("db.Query('.') != 3 keys (%q)", all)
+	}
+
+	for _, path := range []string{"status", ".tags[x]", ".a..b", ".tags[0]x"} {
+		if _, err := db.Query(path, func(any) bool { return true }); !errors.Is(err, ErrQueryPath) {
+			t.Errorf("db.Query(%q) != ErrQueryPath (%v)", path, err)
+		}
+	}
+}
+
+func TestView(t *testing.T) {
+	db := New[int]()
+	db.Add("alice", 100)
+
+	sum := func(_ string, balance int, acc int) int { return acc + balance }
+	total := NewView(db, 0, sum)
+	incremental := NewView(db, 0, sum).Retract(func(_ string, balance int, acc int) int { return acc - balance })
+
+	db.Add("bob", 50).Add("carol", 25)
+	if total.Value() != 175 || incremental.Value() != 175 {
+		t.Errorf("sum != 175 (%d, %d)", total.Value(), incremental.Value())
+	}
+
+	db.Add("alice", 10).Del("bob")
+	if total.Value() != 35 || incremental.Value() != 35 {
+		t.Errorf("sum after update and delete != 35 (%d, %d)", total.Value(), incremental.Value())
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Add(fmt.Sprintf("user:%d", i), 1)
+			_ = incremental.Value()
+		}()
+	}
+	wg.Wait()
+	if total.Value() != 135 || incremental.Value() != 135 {
+		t.Errorf("sum after concurrent adds != 135 (%d, %d)", total.Value(), incremental.Value())
+	}
+}
+
+func TestDB_Trigger(t *testing.T) {
+	db := New[string]().Workers(2)
+	events := make(chan Event[string], 16)
+	if err := db.Trigger("session/*", func(event Event[string]) {
+		if event.Value == "panic" {
+			panic("trigger panicked")
+		}
+		events <- event
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Trigger("[", func(Event[string]) {}); err == nil {
+		t.Errorf("db.Trigger('[') == nil")
+	}
+
+	db.Add("session/1", "panic")
+	db.Add("user/1", "ignored")
+	db.Add("session/2", "alice")
+	db.Add("session/2", "bob")
+	db.Del("session/2")
+
+	kinds := map[EventKind]Event[string]{}
+	for range 3 {
+		select {
+		case event := <-events:
+			kinds[event.Kind] = event
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for trigger events (%v)", kinds)
+		}
+	}
+	if kinds[EventAdded].Value != "alice" || kinds[EventUpdated].Previous != "alice" || kinds[EventDeleted].Previous != "bob" {
+		t.Errorf("unexpected trigger events (%+v)", kinds)
+	}
+
+	db.Add("session/3", "still alive")
+	select {
+	case event := <-events:
+		if event.Key != "session/3" {
+			t.Errorf("event.Key != 'session/3' (%q)", event.Key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("trigger pool did not survive a panic")
+	}
+}
+
+func TestDB_Overflow(t *testing.T) {
+	db := New[int]().Workers(1).Overflow(OverflowDrop)
+	release := make(chan struct{})
+	calls := atomic.Int64{}
+	if err := db.Trigger("*", func(Event[int]) {
+		<-release
+		calls.Add(1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 1000 {
+		db.Add("key", i)
+	}
+	close(release)
+	time.Sleep(time.Millisecond * 50)
+	if n := calls.Load(); n == 0 || n >= 1000 {
+		t.Errorf("calls != (0, 1000) with OverflowDrop (%d)", n)
+	}
+
+	before := runtime.NumGoroutine()
+	queued := New[int]().Workers(1).Overflow(OverflowQueue)
+	release, calls = make(chan struct{}), atomic.Int64{}
+	if err := queued.Trigger("*", func(Event[int]) {
+		<-release
+		calls.Add(1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		queued.Add("key", i)
+	}
+	if n := runtime.NumGoroutine() - before; n > 10 {
+		t.Errorf("OverflowQueue started %d goroutines for 1000 callbacks", n)
+	}
+	queued.Workers(2)
+	close(release)
+	for i := range 1000 {
+		queued.Add("key", i)
+	}
+	if err := queued.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2000 {
+		t.Errorf("calls != 2000 with OverflowQueue across Workers (%d)", n)
+	}
+	if n := runtime.NumGoroutine() - before; n > 2 {
+		t.Errorf("%d goroutines left after Shutdown", n)
+	}
+
+	panicky := New[int]().Timeout(time.Millisecond*40).RefreshAhead(0.5, func(string, int) (int, error) {
+		panic("refresh panicked")
+	})
+	panicky.Add("key", 1)
+	time.Sleep(time.Millisecond * 60)
+	if _, ok := panicky.TryGet("key"); ok {
+		t.Errorf("key survived a panicking refresh")
+	}
+}
+
+func TestDB_Shutdown(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	db := New[string]().Timeout(time.Hour).RefreshAhead(0.5, func(_ string, value string) (string, error) {
+		return value, nil
+	})
+	if err := db.Trigger("*", func(Event[string]) { time.Sleep(time.Millisecond * 10) }); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range testKeys {
+		db.Add(key, key)
+	}
+
+	cache, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Timeout(time.Hour)
+	if err := cache.Add("hello", "world"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := db.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked after Shutdown (%d > %d)", after, before)
+	}
+	if db.Get("green") != "green" {
+		t.Errorf("db.Get('green') != \"green\" after Shutdown")
+	}
+}
+
+type panickingEncoder struct{}
+
+func (panickingEncoder) Encode(any) error { panic("encoder panicked") }
+
+func TestDB_Panics(t *testing.T) {
+	db := New[SortedSet]().Panics(PanicRecover).Guard(func(op Op, key string) error {
+		if key == "boom" {
+			panic("guard panicked")
+		}
+		return nil
+	})
+
+	panicErr := &PanicError{}
+	if err := ZAdd(db, "boom", "member", 1); !errors.As(err, &panicErr) || len(panicErr.Stack) == 0 {
+		t.Errorf("ZAdd('boom') != *PanicError with stack (%v)", err)
+	}
+	db.Add("boom", SortedSet{})
+	if err := ZAdd(db, "fine", "member", 1); err != nil {
+		t.Errorf("ZAdd('fine') != nil (%v)", err)
+	}
+
+	propagating := New[string]().Guard(func(Op, string) error { panic("guard panicked") })
+	defer func() {
+		if recover() == nil {
+			t.Errorf("PanicPropagate did not propagate the panic")
+		}
+	}()
+	propagating.Add("key", "value")
+}
+
+func TestDBCache_Panics(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	if _, err := From[string](filename); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Fromf[string](
+		filename,
+		func(io.Writer) panickingEncoder { return panickingEncoder{} },
+		json.NewDecoder,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Panics(PanicRecover)
+
+	panicErr := &PanicError{}
+	if err := db.Add("key", "value"); !errors.As(err, &panicErr) || panicErr.Value != "encoder panicked" {
+		t.Errorf("db.Add() != *PanicError (%v)", err)
+	}
+}
+
+func TestDBCache_OnError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	db, err := From[string](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported := make(chan string, 4)
+	db.OnError(func(op string, key string, err error) {
+		reported <- op
+	})
+
+	if err := os.WriteFile(filename, []byte("{broken"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Second)
+	if err := os.Chtimes(filename, future, future); err != nil {
+		t.Fatal(err)
+	}
+	for range db.Seq2() {
+	}
+
+	select {
+	case op := <-reported:
+		if op != "seq2" {
+			t.Errorf("reported op != \"seq2\" (%q)", op)
+		}
+	default:
+		t.Errorf("Seq2 reload failure was not reported")
+	}
+}
+
+func TestDB_OnError(t *testing.T) {
+	reported := make(chan error, 4)
+	db := New[int]().Quota("", Quota{MaxEntries: 1}).OnError(func(op string, key string, err error) {
+		reported <- err
+	})
+
+	db.Add("a", 1).Add("b", 2)
+	select {
+	case err := <-reported:
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("reported error != ErrQuotaExceeded (%v)", err)
+		}
+	default:
+		t.Errorf("dropped Add was not reported")
+	}
+}
+
+func TestDBCache_Consistency(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	relaxed, err := From[string](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relaxed.Consistency(Relaxed).SyncInterval(time.Millisecond * 50)
+
+	if err := relaxed.Add("hello", "world"); err != nil {
+		t.Fatal(err)
+	}
+	onDisk := func() map[string]string {
+		data := map[string]string{}
+		raw, _ := os.ReadFile(filename)
+		_ = json.Unmarshal(raw, &data)
+		return data
+	}
+	if _, ok := onDisk()["hello"]; ok {
+		t.Errorf("Relaxed Add was saved synchronously")
+	}
+	time.Sleep(time.Millisecond * 80)
+	if onDisk()["hello"] != "world" {
+		t.Errorf("Relaxed Add was not saved by the syncer")
+	}
+
+	if err := relaxed.Add("bye", "world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := relaxed.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if onDisk()["bye"] != "world" {
+		t.Errorf("Shutdown did not save pending Relaxed writes")
+	}
+
+	own, err := From[string](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	own.Consistency(ReadOwnWrites)
+	other, err := From[string](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := own.Add("mine", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := own.Get("mine"); value != "value" {
+		t.Errorf("ReadOwnWrites lost its own write")
+	}
+	time.Sleep(time.Millisecond * 10)
+	if err := other.Add("theirs", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := own.Get("theirs"); value != "value" {
+		t.Errorf("ReadOwnWrites did not pick up an external change")
+	}
+}
+
+func TestDB_Warm(t *testing.T) {
+	inflight, peak := atomic.Int64{}, atomic.Int64{}
+	loader := func(ctx context.Context, key string) (string, error) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for old := peak.Load(); n > old && !peak.CompareAndSwap(old, n); old = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		if key == "broken" {
+			return "", errors.New("no such key")
+		}
+		return strings.ToUpper(key), nil
+	}
+
+	db := New[string]()
+	if err := db.Warm(context.Background(), testKeys, 4, nil); !errors.Is(err, ErrNoLoader) {
+		t.Errorf("db.Warm() without loader != ErrNoLoader (%v)", err)
+	}
+
+	db.Loader(loader).Add("green", "already here")
+	lastDone := atomic.Int64{}
+	err := db.Warm(context.Background(), append(slices.Clone(testKeys[:50]), "broken"), 4, func(done, total int) {
+		if total != 50 {
+			t.Errorf("progress total != 50 (%d)", total)
+		}
+		lastDone.Store(int64(done))
+	})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("db.Warm() error does not mention the broken key (%v)", err)
+	}
+	if peak.Load() > 4 {
+		t.Errorf("concurrency exceeded 4 (%d)", peak.Load())
+	}
+	if lastDone.Load() != 50 {
+		t.Errorf("final progress != 50 (%d)", lastDone.Load())
+	}
+	if db.Get("green") != "already here" || db.Get("cyan") != "CYAN" {
+		t.Errorf("db.Warm() loaded wrong values (%q, %q)", db.Get("green"), db.Get("cyan"))
+	}
+
+	racy := New[string]()
+	racy.Loader(func(ctx context.Context, key string) (string, error) {
+		racy.Add(key, "written meanwhile")
+		return "loaded", nil
+	})
+	if err := racy.Warm(context.Background(), []string{"key"}, 1, nil); err != nil || racy.Get("key") != "written meanwhile" {
+		t.Errorf("racy.Warm() overwrote a concurrent Add (%v, %q)", err, racy.Get("key"))
+	}
+
+	cache, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Loader(loader).Warm(context.Background(), testKeys[:10], 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := cache.Len(); n != 10 {
+		t.Errorf("cache.Len() after Warm != 10 (%d)", n)
+	}
+}
+
+func TestDBCache_WithSeed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	live, err := From[*TestingUser](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := live.Add("@green", &TestingUser{1, "Modified"}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := From[*TestingUser](filename, WithSeed("testdata/cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := db.Get("@green"); user.Name != "Modified" {
+		t.Errorf("seed overwrote a live value (%q)", user.Name)
+	}
+	if user, _ := db.Get("@red"); user == nil || user.Name != "Doe" {
+		t.Errorf("seed default '@red' missing (%v)", user)
+	}
+	if n, _ := db.Len(); n != 15 {
+		t.Errorf("db.Len() != 15 (%d)", n)
+	}
+
+	if err := db.Del("@red"); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := From[*TestingUser](filename, WithSeed("testdata/cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := reopened.TryGet("@red"); ok {
+		t.Errorf("deleted default came back on reopening")
+	}
+
+	if _, err := From[*TestingUser](filename, WithSeed("testdata/missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing seed != os.ErrNotExist (%v)", err)
+	}
+}
+
+func TestDBCache_WithEnv(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "from-env.json")
+	t.Setenv("MYAPP_CACHE_FILE", filename)
+	t.Setenv("MYAPP_CACHE_TIMEOUT", "50ms")
+	t.Setenv("MYAPP_CACHE_CONSISTENCY", "read-own-writes")
+
+	db, err := From[string]("ignored.json", WithEnv("MYAPP_CACHE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.cache != filename || db.timeout != time.Millisecond*50 || db.consistency != ReadOwnWrites {
+		t.Errorf("env overrides not applied (%q, %v, %v)", db.cache, db.timeout, db.consistency)
+	}
+	if _, err := os.Stat("ignored.json"); !os.IsNotExist(err) {
+		t.Errorf("overridden file was created")
+	}
+	db.Timeout(time.Hour).Consistency(Strict)
+	if db.timeout != time.Millisecond*50 || db.consistency != ReadOwnWrites {
+		t.Errorf("setters overrode the env (%v, %v)", db.timeout, db.consistency)
+	}
+
+	t.Setenv("MYAPP_CACHE_CONSISTENCY", "eventual")
+	if _, err := From[string]("ignored.json", WithEnv("MYAPP_CACHE")); err == nil {
+		t.Errorf("unknown consistency mode accepted")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	from := New[string]()
+	for _, key := range testKeys[:20] {
+		from.Add(key, key)
+	}
+	to, err := Fromf[string](filepath.Join(t.TempDir(), "cache.json"), json.NewEncoder, json.NewDecoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dual := NewDualWrite[string](from, to)
+	if err := Migrate[string](from, to); err != nil {
+		t.Fatal(err)
+	}
+	if err := dual.Add("late", "write"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dual.Del(testKeys[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := to.Len(); n != 20 {
+		t.Errorf("to.Len() != 20 (%d)", n)
+	}
+	if value, _ := to.Get("late"); value != "write" {
+		t.Errorf("dual write did not reach the secondary store")
+	}
+	if _, ok, _ := dual.TryGet(testKeys[0]); ok {
+		t.Errorf("dual delete did not reach the primary store")
+	}
+	if !to.lifetimes[testKeys[1]].Equal(from.lifetimes[testKeys[1]]) {
+		t.Errorf("migrated lifetime differs")
+	}
+}
+
+func TestDBCache_Resolve(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	mine, err := Fromf[int](filename, json.NewEncoder, json.NewDecoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine.Resolve(func(key string, mine, theirs Versioned[int]) int { return max(mine.Value, theirs.Value) })
+	if err := mine.Add("a", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := mine.Add("b", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(filename, []byte(`{"a":5,"b":7}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := mine.Get("a"); value != 10 {
+		t.Errorf("a: %d != 10", value)
+	}
+	if value, _ := mine.Get("b"); value != 7 {
+		t.Errorf("b: %d != 7", value)
+	}
+
+	db := New[int]().Resolve(func(key string, mine, theirs Versioned[int]) int { return mine.Value + theirs.Value })
+	db.Add("a", 1)
+	if err := Migrate[int](mine, db); err != nil {
+		t.Fatal(err)
+	}
+	if value := db.Get("a"); value != 11 {
+		t.Errorf("migrated a: %d != 11", value)
+	}
+}
+
+func TestDB_JSON(t *testing.T) {
+	type state struct {
+		Name  string      `json:"name"`
+		Users *DB[int]    `json:"users"`
+		Empty *DB[int]    `json:"empty"`
+		Cache *DB[string] `json:"cache"`
+	}
+	in := state{Name: "test", Users: New[int](), Empty: New[int](), Cache: New[string]().Timeout(time.Hour)}
+	in.Users.Add("alice", 1).Add("bob", 2)
+	in.Cache.Add("key", "value")
+
+	encoded, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := state{}
+	if err := json.Unmarshal(encoded, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Users.Len() != 2 || out.Users.Get("bob") != 2 {
+		t.Errorf("users not restored: %s", encoded)
+	}
+	if out.Empty.Len() != 0 {
+		t.Errorf("empty db not empty")
+	}
+	if out.Cache.timeout != time.Hour || !out.Cache.lifetimes["key"].Equal(in.Cache.lifetimes["key"]) {
+		t.Errorf("metadata not restored: %s", encoded)
+	}
+	if !strings.Contains(string(encoded), `"users":{"data":{"alice":1,"bob":2}}`) {
+		t.Errorf("unexpected encoding: %s", encoded)
+	}
+}
+
+func TestDB_GobAndString(t *testing.T) {
+	db := New[string]().Timeout(time.Hour).Add("secret-key", "secret-value")
+
+	encoded := &bytes.Buffer{}
+	if err := gob.NewEncoder(encoded).Encode(db); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &DB[string]{}
+	if err := gob.NewDecoder(encoded).Decode(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Get("secret-key") != "secret-value" || decoded.timeout != time.Hour {
+		t.Errorf("gob round trip lost data")
+	}
+
+	logged := &bytes.Buffer{}
+	slog.New(slog.NewTextHandler(logged, nil)).Info("state", "db", db)
+	for _, text := range []string{fmt.Sprint(db), logged.String()} {
+		if strings.Contains(text, "secret") {
+			t.Errorf("output leaks entries: %s", text)
+		}
+	}
+	if !strings.Contains(logged.String(), "db.len=1") {
+		t.Errorf("unexpected log: %s", logged)
+	}
+
+	db.mutex.Lock()
+	if text := db.String(); text != "nanodb.DB{busy}" {
+		t.Errorf("String on a locked DB: %s", text)
+	}
+	db.mutex.Unlock()
+}
+
+func TestDBCache_Stats(t *testing.T) {
+	db, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	db.Get("key")
+	db.Get("key")
+	db.TryGet("missing")
+
+	stats := db.Stats()
+	if stats.Len != 1 || stats.Hits != 2 || stats.Misses != 1 || stats.LastSave.IsZero() {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if rate := stats.HitRate(); math.Abs(rate-2.0/3) > 1e-9 {
+		t.Errorf("hit rate %f", rate)
+	}
+
+	logged := &bytes.Buffer{}
+	slog.New(slog.NewTextHandler(logged, nil)).Info("health", "cache", db)
+	for _, text := range []string{"cache.len=1", "cache.hits=2", "cache.misses=1", "cache.last_save="} {
+		if !strings.Contains(logged.String(), text) {
+			t.Errorf("log lacks %q: %s", text, logged)
+		}
+	}
+}
+
+func TestDB_ExpiringWithin(t *testing.T) {
+	db := New[int]().Timeout(time.Hour)
+	db.Add("old", 1).Add("new", 2)
+	db.mutex.Lock()
+	db.lifetimes["old"] = time.Now().Add(-55 * time.Minute)
+	db.mutex.Unlock()
+
+	keys := []string{}
+	for key := range db.ExpiringWithin(10 * time.Minute) {
+		keys = append(keys, key)
+	}
+	if !slices.Equal(keys, []string{"old"}) {
+		t.Errorf("expiring keys: %v", keys)
+	}
+	for key := range New[int]().Add("forever", 1).ExpiringWithin(time.Hour) {
+		t.Errorf("%s expires without a timeout", key)
+	}
+
+	for key, value := range db.ExpiringWithin(10 * time.Minute) {
+		db.Add(key, value+10)
+	}
+	if db.Get("old") != 11 {
+		t.Errorf("renewing inside the loop: old = %d", db.Get("old"))
+	}
+
+	cache, err := From[int](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Timeout(time.Hour).Add("old", 1); err != nil {
+		t.Fatal(err)
+	}
+	cache.mutex.Lock()
+	cache.lifetimes["old"] = time.Now().Add(-55 * time.Minute)
+	cache.mutex.Unlock()
+	for key, value := range cache.ExpiringWithin(10 * time.Minute) {
+		if err := cache.Add(key, value+10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, _ := cache.Get("old"); value != 11 {
+		t.Errorf("renewing a DBCache entry inside the loop: old = %d", value)
+	}
+}
+
+func TestDB_CleanupPolicy(t *testing.T) {
+	db := New[string]()
+	db.Add("old", "value").Add("new", "value").Add("big", strings.Repeat("x", 100))
+	db.mutex.Lock()
+	db.lifetimes["old"] = time.Now().Add(-31 * 24 * time.Hour)
+	db.mutex.Unlock()
+
+	db.CleanupPolicy(10*time.Millisecond, func(key string, meta EntryMeta, value string) bool {
+		return time.Since(meta.Modified) < 30*24*time.Hour && meta.Size < 100
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	if keys := db.KeysSnapshot(); !slices.Equal(keys, []string{"new"}) {
+		t.Errorf("keys after cleanup: %v", keys)
+	}
+	if err := db.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDBCache_CleanupPolicy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	db, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if err := db.Add(fmt.Sprint(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.CleanupPolicy(10*time.Millisecond, func(key string, meta EntryMeta, value int) bool { return value%2 == 0 })
+	time.Sleep(50 * time.Millisecond)
+	if err := db.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := reopened.Len(); n != 5 {
+		t.Errorf("%d entries left after cleanup", n)
+	}
+}
+
+func TestDB_ArchiveOnExpiry(t *testing.T) {
+	cold, err := From[string](filepath.Join(t.TempDir(), "cold.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot := New[string]().Timeout(20 * time.Millisecond).Archive(ArchiveTo[string](cold))
+	hot.Add("key", "value")
+	hot.Add("deleted", "value").Del("deleted")
+
+	time.Sleep(100 * time.Millisecond)
+	if err := hot.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hot.TryGet("key"); ok {
+		t.Errorf("entry did not expire")
+	}
+	if value, _ := cold.Get("key"); value != "value" {
+		t.Errorf("expired entry was not archived")
+	}
+	if _, ok, _ := cold.TryGet("deleted"); ok {
+		t.Errorf("deleted entry was archived")
+	}
+}
+
+func TestDB_NotifyExpired(t *testing.T) {
+	received := make(chan Expired[string], 4)
+	requests := atomic.Int32{}
+	var posted []Expired[string]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	db := New[string]().
+		Timeout(20*time.Millisecond).
+		NotifyExpired(ToChannel(received), 2, time.Hour).
+		NotifyExpired(Retry(Webhook[string](server.Client(), server.URL), 2, time.Millisecond), 10, 10*time.Millisecond)
+	db.Add("a", "1").Add("b", "2").Add("deleted", "3").Del("deleted")
+
+	time.Sleep(100 * time.Millisecond)
+	if err := db.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(received)
+	keys := []string{}
+	for expired := range received {
+		keys = append(keys, expired.Key)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("channel received %v", keys)
+	}
+	if len(posted) != 2 || requests.Load() != 2 {
+		t.Errorf("webhook received %v after %d requests", posted, requests.Load())
+	}
+}
+
+func TestTiered(t *testing.T) {
+	cold, err := From[int](filepath.Join(t.TempDir(), "cold.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot := New[int]()
+	tiered := NewTiered[int](hot, cold, 3)
+	for i := range 5 {
+		if err := tiered.Add(fmt.Sprint(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hot.Len() != 3 {
+		t.Errorf("hot tier holds %d entries", hot.Len())
+	}
+	if n, _ := cold.Len(); n != 2 {
+		t.Errorf("cold tier holds %d entries", n)
+	}
+
+	value, ok, err := tiered.TryGet("0")
+	if err != nil || !ok || value != 0 {
+		t.Fatalf("TryGet(0) = %d, %v, %v", value, ok, err)
+	}
+	if _, ok := hot.TryGet("0"); !ok {
+		t.Errorf("cold entry was not promoted")
+	}
+	if _, ok := hot.TryGet("2"); ok {
+		t.Errorf("least recently used entry was not demoted")
+	}
+	if err := tiered.Del("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := tiered.TryGet("1"); ok {
+		t.Errorf("deleted cold entry still readable")
+	}
+
+	if err := cold.Add("locked", 1); err != nil {
+		t.Fatal(err)
+	}
+	hot.Guard(func(op Op, key string) error {
+		if op != OpGet && key == "locked" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	if err := tiered.Add("locked", 2); err == nil {
+		t.Errorf("tiered.Add past the hot guard succeeded")
+	}
+	if err := tiered.Del("locked"); err == nil {
+		t.Errorf("tiered.Del past the hot guard succeeded")
+	}
+	if value, err := cold.Get("locked"); err != nil || value != 1 {
+		t.Errorf("refused writes dropped the cold copy (%d, %v)", value, err)
+	}
+}
+
+func TestDBCache_MemFS(t *testing.T) {
+	fsys := NewMemFS()
+	db, err := From[string]("cache.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("cache.json"); !os.IsNotExist(err) {
+		t.Errorf("MemFS wrote to disk: %v", err)
+	}
+
+	reopened, err := From[string]("cache.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := reopened.Get("key"); value != "value" {
+		t.Errorf("reopened MemFS lost the entry")
+	}
+
+	snapshot := fstest.MapFS{"snapshot.json": {Data: []byte(`{"embedded":"value"}`)}}
+	readOnly, err := From[string]("snapshot.json", WithFS(ReadOnlyFS(snapshot)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := readOnly.Get("embedded"); value != "value" {
+		t.Errorf("read-only snapshot not loaded")
+	}
+	if err := readOnly.Add("key", "value"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("write to a read-only FS: %v", err)
+	}
+}
+
+func TestDB_Analyze(t *testing.T) {
+	db := New[string]().Timeout(time.Hour)
+	for i := range 100 {
+		db.Add(fmt.Sprintf("user/%d", i), strings.Repeat("a", i))
+	}
+	db.Add("config:theme", "dark").Add("plain", "value")
+
+	report := db.Analyze()
+	if report.Keys != 102 || report.Prefixes["user/"] != 100 || report.Prefixes["config:"] != 1 || report.Prefixes[""] != 1 {
+		t.Errorf("unexpected key counts: %+v", report.Prefixes)
+	}
+	if report.Sizes.Min != 3 || report.Sizes.Max != 102 {
+		t.Errorf("unexpected sizes: %+v", report.Sizes)
+	}
+	if report.Compression <= 0 || report.Compression >= 0.5 {
+		t.Errorf("repeated values should compress well: %f", report.Compression)
+	}
+	if report.TTL.Max > time.Hour || report.TTL.Min < 59*time.Minute {
+		t.Errorf("unexpected ttls: %+v", report.TTL)
+	}
+	if !strings.Contains(report.String(), `"user/"`) {
+		t.Errorf("report does not list prefixes:\n%s", report)
+	}
+}
+
+func TestDB_TrackAccess(t *testing.T) {
+	db := New[int]().TrackAccess(1024)
+	for i := range 10 {
+		db.Add(fmt.Sprint(i), i)
+	}
+	for i := range 10 {
+		for range i * 3 {
+			db.Get(fmt.Sprint(i))
+		}
+	}
+
+	hottest := db.Hottest(2)
+	if len(hottest) != 2 || hottest[0].Key != "9" || hottest[1].Key != "8" || hottest[0].Count < 27 {
+		t.Errorf("unexpected hottest: %v", hottest)
+	}
+	if coldest := db.Coldest(1); len(coldest) != 1 || coldest[0].Key != "0" {
+		t.Errorf("unexpected coldest: %v", coldest)
+	}
+	if New[int]().Hottest(1) != nil {
+		t.Errorf("Hottest without tracking")
+	}
+}
+
+func TestDB_AdaptiveTTL(t *testing.T) {
+	db := New[int]().Timeout(time.Hour).AdaptiveTTL(30*time.Millisecond, time.Second)
+	db.Add("hot", 1).Add("cold", 2)
+	for range 100 {
+		db.Get("hot")
+	}
+	db.mutex.Lock()
+	hot, cold := db.ttl("hot"), db.ttl("cold")
+	db.mutex.Unlock()
+	if hot != time.Second || cold < 30*time.Millisecond || cold > 60*time.Millisecond {
+		t.Errorf("ttl(hot) = %v, ttl(cold) = %v", hot, cold)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, ok := db.TryGet("cold"); ok {
+		t.Errorf("cold key outlived its shortened ttl")
+	}
+	if _, ok := db.TryGet("hot"); !ok {
+		t.Errorf("hot key expired early")
+	}
+}
+
+func TestDB_SampledExpiration(t *testing.T) {
+	db := New[int]().Timeout(20*time.Millisecond).SampledExpiration(5*time.Millisecond, 20)
+	for i := range 1000 {
+		db.Add(fmt.Sprint(i), i)
+	}
+	db.mutex.Lock()
+	if n := len(db.expiries.byKey); n != 0 {
+		t.Errorf("%d expiration timers with sampling enabled", n)
+	}
+	db.mutex.Unlock()
+
+	time.Sleep(25 * time.Millisecond)
+	if _, ok := db.TryGet("0"); ok {
+		t.Errorf("expired entry readable before it was sampled")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := db.Len(); n != 0 {
+		t.Errorf("%d expired entries left after sampling", n)
+	}
+	if err := db.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_XFetch(t *testing.T) {
+	recomputed := atomic.Int32{}
+	db := New[int]().Timeout(100*time.Millisecond).XFetch(1, func(key string, value int) (int, error) {
+		recomputed.Add(1)
+		return value + 1, nil
+	})
+	db.Add("key", 0)
+	db.mutex.Lock()
+	db.lifetimes["key"] = time.Now().Add(-99 * time.Millisecond)
+	db.mutex.Unlock()
+
+	for range 100 {
+		db.Get("key")
+	}
+	if err := db.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := recomputed.Load(); n != 1 {
+		t.Errorf("recomputed %d times", n)
+	}
+	if value := db.Get("key"); value != 1 {
+		t.Errorf("value %d was not recomputed", value)
+	}
+}
+
+func TestDB_Watch(t *testing.T) {
+	db := New[int]()
+	events := atomic.Int32{}
+	stop, err := db.Watch("*", func(event Event[int]) { events.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Add("a", 1)
+	stop()
+	db.Add("b", 2)
+	if err := db.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := events.Load(); n != 1 {
+		t.Errorf("%d events, the stopped watch kept firing", n)
+	}
+}
+
+func TestDB_Reconfigure(t *testing.T) {
+	db := New[string]().Timeout(time.Hour)
+	db.Add("old", "value")
+	db.mutex.Lock()
+	db.lifetimes["old"] = time.Now().Add(-time.Minute)
+	db.mutex.Unlock()
+	db.Add("new", "value")
+
+	db.Reconfigure(TTL(30*time.Second), Limit("new", Quota{MaxEntries: 1}))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := db.TryGet("old"); ok {
+		t.Errorf("entry older than the new ttl survived")
+	}
+	if _, ok := db.TryGet("new"); !ok {
+		t.Errorf("entry younger than the new ttl expired")
+	}
+	db.Add("newer", "value")
+	if _, ok := db.TryGet("newer"); ok {
+		t.Errorf("reconfigured quota not applied")
+	}
+}
+
+func TestDB_Batch(t *testing.T) {
+	db := New[int]().Quota("limited/", Quota{MaxEntries: 1})
+	db.Add("old", 0)
+	if err := db.Batch().Add("a", 1).Add("limited/1", 1).Add("limited/2", 2).Commit(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Commit over quota = %v", err)
+	}
+	if db.Len() != 1 {
+		t.Errorf("failed batch was partially applied")
+	}
+
+	batch := db.Batch().Add("a", 1).Add("limited/1", 1).Del("limited/1").Add("limited/2", 2).Del("old")
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if batch.Len() != 0 || db.Len() != 2 || db.Get("limited/2") != 2 {
+		t.Errorf("unexpected db after commit: %v", db)
+	}
+
+	groups := [][]change[int]{}
+	db.mutex.Lock()
+	db.addObserver(func(changes []change[int]) { groups = append(groups, changes) })
+	db.mutex.Unlock()
+	events := make(chan string, 16)
+	if err := db.Trigger("*", func(event Event[int]) { events <- event.Key }); err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"x", "y", "z", "w", "v", "u"}
+	batch = db.Batch()
+	for i, key := range keys {
+		batch.Add(key, i)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0]) != len(keys) {
+		t.Errorf("batch reached observers as %d groups", len(groups))
+	}
+	for _, key := range keys {
+		select {
+		case got := <-events:
+			if got != key {
+				t.Errorf("batch event for %q out of order, expected %q", got, key)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("batch event for %q not delivered", key)
+		}
+	}
+}
+
+func TestDBCache_Batch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "batch.json")
+	db, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Guard(func(op Op, key string) error {
+		if op == OpDel && key == "protected" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	if err := db.Batch().Add("a", 1).Del("protected").Commit(); err == nil {
+		t.Errorf("denied batch committed")
+	}
+	if err := db.Batch().Add("a", 1).Add("b", 2).Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := reopened.Len(); n != 2 {
+		t.Errorf("%d entries saved", n)
+	}
+}
+
+type slowFS struct {
+	*MemFS
+	creates atomic.Int32
+}
+
+func (fsys *slowFS) Create(name string) (io.WriteCloser, error) {
+	fsys.creates.Add(1)
+	time.Sleep(5 * time.Millisecond)
+	return fsys.MemFS.Create(name)
+}
+
+func TestDBCache_GroupCommit(t *testing.T) {
+	fsys := &slowFS{MemFS: NewMemFS()}
+	db, err := From[int]("cache.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys.creates.Store(0)
+
+	wg := sync.WaitGroup{}
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Add(fmt.Sprint(i), i); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fsys.creates.Load(); n >= 50 {
+		t.Errorf("%d saves for 50 concurrent writes", n)
+	}
+
+	reopened, err := From[int]("cache.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := reopened.Len(); n != 50 {
+		t.Errorf("%d of 50 grouped writes saved", n)
+	}
+}
+
+func TestDBCache_Backpressure(t *testing.T) {
+	fsys := &slowFS{MemFS: NewMemFS()}
+	db, err := From[int]("cache.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Consistency(Relaxed).SyncInterval(time.Hour).Backpressure(2, false)
+	defer db.Shutdown(context.Background())
+
+	for i := range 2 {
+		if err := db.Add(fmt.Sprint(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Add("2", 2); !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected ErrBusy past the limit, got %v", err)
+	}
+	if value, loaded, err := db.GetOrSet("1", 10); err != nil || !loaded || value != 1 {
+		t.Errorf("GetOrSet hit past the limit = %d, %v, %v", value, loaded, err)
+	}
+	if _, _, err := db.GetOrSet("2", 2); !errors.Is(err, ErrBusy) {
+		t.Errorf("GetOrSet miss past the limit = %v", err)
+	}
+
+	fsys.creates.Store(0)
+	db.Backpressure(2, true)
+	if err := db.Add("2", 2); err != nil {
+		t.Fatal(err)
+	}
+	if n := fsys.creates.Load(); n != 1 {
+		t.Errorf("blocked writer saved %d times, expected once", n)
+	}
+
+	db.Consistency(Strict).Backpressure(1, true)
+	wg := sync.WaitGroup{}
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Add(fmt.Sprint(i), i); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := db.Len(); n != 10 {
+		t.Errorf("%d of 10 throttled writes stored", n)
+	}
+}
+
+func TestDBCache_Seq2Snapshot(t *testing.T) {
+	db, err := From[int](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := db.Add(fmt.Sprint(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := 0
+	for key := range db.Seq2() {
+		if err := db.Add(key+"-copy", 0); err != nil {
+			t.Fatal(err)
+		}
+		seen++
+	}
+	if n, _ := db.Len(); seen != 3 || n != 6 {
+		t.Errorf("iterated %d entries, %d after writing during iteration", seen, n)
+	}
+}
+
+var lazyDecodes atomic.Int32
+
+type lazyValue struct {
+	V int
+}
+
+func (value *lazyValue) UnmarshalJSON(data []byte) error {
+	lazyDecodes.Add(1)
+	type plain lazyValue
+	return json.Unmarshal(data, (*plain)(value))
+}
+
+func TestDBCache_WithLazyDecoding(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(filename, []byte(`{"a":{"V":1},"b":{"V":2},"c":{"V":3}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	lazyDecodes.Store(0)
+	db, err := From[lazyValue](filename, WithLazyDecoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := db.Get("a"); err != nil || value.V != 1 {
+		t.Fatalf("Get(a) = %v, %v", value, err)
+	}
+	if _, err := db.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if n := lazyDecodes.Load(); n != 1 {
+		t.Errorf("%d decodes after reading one key twice", n)
+	}
+
+	if err := db.Add("d", lazyValue{V: 4}); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := From[lazyValue](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := reopened.Len(); n != 4 {
+		t.Errorf("%d entries saved, raw entries were lost", n)
+	}
+	if n, _ := db.Len(); n != 4 {
+		t.Errorf("Len = %d with raw entries", n)
+	}
+}
+
+func TestDBCache_WithProjection(t *testing.T) {
+	type profile struct {
+		Id     int
+		Name   string `json:"name"`
+		Avatar []byte
+	}
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	full, err := From[profile](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := full.Add("user", profile{Id: 1, Name: "kitten", Avatar: []byte("large")}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := From[profile](filename, WithProjection("Id", "Name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := db.Get("user"); err != nil || value.Id != 1 || value.Name != "kitten" || value.Avatar != nil {
+		t.Errorf("Get(user) = %+v, %v", value, err)
+	}
+	if err := db.Add("other", profile{}); !errors.Is(err, ErrProjected) {
+		t.Errorf("Add to a projected cache = %v", err)
+	}
+}
+
+func TestDB_Index(t *testing.T) {
+	type account struct {
+		Email string `nanodb:"index"`
+		Team  *string
+		Notes []string `nanodb:"index"`
+	}
+	db := New[account]()
+	db.Add("alice", account{Email: "a@example.com"}).Add("bob", account{Email: "b@example.com"})
+	db.Add("alice", account{Email: "alice@example.com"}).Add("carol", account{Email: "b@example.com"}).Del("bob")
+	if keys := db.Lookup("Email", "b@example.com"); !slices.Equal(keys, []string{"carol"}) {
+		t.Errorf("Lookup(b@example.com) = %v", keys)
+	}
+	if keys := db.Lookup("Email", "a@example.com"); keys != nil {
+		t.Errorf("stale index entry %v", keys)
+	}
+	if keys := db.Lookup("Notes", nil); keys != nil {
+		t.Errorf("non-comparable field was indexed")
+	}
+
+	db.Index("team", func(value account) any {
+		if value.Team == nil {
+			return nil
+		}
+		return *value.Team
+	})
+	team := "core"
+	db.Add("dave", account{Team: &team})
+	if keys := db.Lookup("team", "core"); !slices.Equal(keys, []string{"dave"}) {
+		t.Errorf("Lookup(team, core) = %v", keys)
+	}
+
+	type tagged struct {
+		Level int8 `nanodb:"index"`
+		Extra any  `nanodb:"index,unique"`
+		Pair  struct {
+			Value any
+		} `nanodb:"index"`
+	}
+	levels := New[tagged]().Add("a", tagged{Level: 3, Extra: []int{1}}).Add("b", tagged{Level: 3, Extra: []int{1}})
+	if keys := levels.Lookup("Level", 3); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("Lookup(Level, 3) with an int8 field = %v", keys)
+	}
+	if _, ok := levels.indexes["Extra"]; ok {
+		t.Errorf("interface field was indexed")
+	}
+	if _, ok := levels.indexes["Pair"]; ok {
+		t.Errorf("struct holding an interface was indexed")
+	}
+	levels.Index("extra", func(value tagged) any { return value.Extra })
+	if keys := levels.Add("c", tagged{Extra: map[string]int{}}).Lookup("extra", nil); keys != nil {
+		t.Errorf("non-comparable value was indexed: %v", keys)
+	}
+}
+
+func TestDB_Unique(t *testing.T) {
+	type account struct {
+		Email string `nanodb:"index,unique"`
+	}
+	reported := make(chan error, 4)
+	db := New[account]().OnError(func(op string, key string, err error) { reported <- err })
+	db.Add("alice", account{Email: "a@example.com"}).Add("mallory", account{Email: "a@example.com"})
+	if _, ok := db.TryGet("mallory"); ok {
+		t.Errorf("duplicate email stored")
+	}
+	if err := <-reported; !errors.Is(err, ErrDuplicate) {
+		t.Errorf("reported %v", err)
+	}
+	db.Add("alice", account{Email: "a@example.com"})
+	if _, ok := db.TryGet("alice"); !ok {
+		t.Errorf("rewriting a key's own value was refused")
+	}
+
+	swap := db.Batch().Add("alice", account{Email: "new@example.com"}).Add("bob", account{Email: "a@example.com"})
+	if err := swap.Commit(); err != nil {
+		t.Errorf("handing a value over within a batch: %v", err)
+	}
+	clash := db.Batch().Add("carol", account{Email: "c@example.com"}).Add("dave", account{Email: "c@example.com"})
+	if err := clash.Commit(); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate within a batch: %v", err)
+	}
+	if err := db.TryAdd("erin", account{Email: "a@example.com"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("TryAdd(erin) = %v", err)
+	}
+
+	from := New[account]().Add("frank", account{Email: "a@example.com"})
+	if err := Migrate[account](from, db); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Migrate of a duplicate = %v", err)
+	}
+	if _, ok := db.TryGet("frank"); ok {
+		t.Errorf("duplicate email restored")
+	}
+}
+
+func TestDB_RequireRef(t *testing.T) {
+	type record struct {
+		Name string
+		User int
+	}
+	db := New[record]()
+	if err := db.RequireRef("order/", "User", "user/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RequireRef("order/", "Missing", "user/"); err == nil {
+		t.Errorf("reference on a missing field accepted")
+	}
+
+	db.Add("order/1", record{User: 7})
+	if _, ok := db.TryGet("order/1"); ok {
+		t.Errorf("order referencing a missing user stored")
+	}
+	db.Add("user/7", record{Name: "kitten"}).Add("order/1", record{User: 7}).Del("user/7")
+	if _, ok := db.TryGet("user/7"); !ok {
+		t.Errorf("referenced user deleted")
+	}
+	if err := db.Batch().Del("order/1").Del("user/7").Commit(); err != nil {
+		t.Errorf("deleting the order with its user: %v", err)
+	}
+	if err := db.Batch().Add("order/2", record{User: 8}).Add("user/8", record{}).Commit(); err != nil {
+		t.Errorf("adding the order with its user: %v", err)
+	}
+	if err := db.Batch().Del("user/8").Commit(); !errors.Is(err, ErrReference) {
+		t.Errorf("Del of a referenced user = %v", err)
+	}
+
+	from := New[record]().Add("order/3", record{User: 9})
+	if err := Migrate[record](from, db); !errors.Is(err, ErrReference) {
+		t.Errorf("Migrate of a dangling order = %v", err)
+	}
+	if err := db.TryAdd("order/3", record{User: 9}); !errors.Is(err, ErrReference) {
+		t.Errorf("TryAdd of a dangling order = %v", err)
+	}
+
+	type order struct{ User string }
+	orders := New[order]()
+	if err := orders.RequireRef("order/", "User", "user/"); err != nil {
+		t.Fatal(err)
+	}
+	orders.NormalizeKeys(LowerCase)
+	orders.Add("user/alice", order{})
+	if err := orders.TryAdd("order/1", order{User: "Alice"}); err != nil {
+		t.Errorf("order referencing Alice as user/alice: %v", err)
+	}
+	if err := orders.TryDel("USER/alice"); !errors.Is(err, ErrReference) {
+		t.Errorf("Del of a user referenced as Alice = %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	key := Key("user", 42, "session", "a/b%c*")
+	if key != "user/42/session/a%2Fb%25c%2A" {
+		t.Errorf("Key = %q", key)
+	}
+	parts, err := ParseKey(key)
+	if err != nil || !slices.Equal(parts, []string{"user", "42", "session", "a/b%c*"}) {
+		t.Errorf("ParseKey(%q) = %q, %v", key, parts, err)
+	}
+	if strings.HasPrefix(Key("user", 10), KeyPrefix("user", 1)) {
+		t.Errorf("KeyPrefix(user, 1) matches user/10")
+	}
+	for _, malformed := range []string{"user/%2", "user/%zz"} {
+		if _, err := ParseKey(malformed); !errors.Is(err, ErrKey) {
+			t.Errorf("ParseKey(%q) = %v", malformed, err)
+		}
+	}
+}
+
+func TestDB_NormalizeKeys(t *testing.T) {
+	db := New[int]().Add("Alice", 1)
+	db.NormalizeKeys(strings.TrimSpace, LowerCase)
+	if value, ok := db.TryGet(" ALICE "); !ok || value != 1 {
+		t.Errorf("stored key was not normalized")
+	}
+	db.Add("Bob", 2).Del("BOB")
+	if db.Len() != 1 {
+		t.Errorf("Del missed a differently cased key")
+	}
+	if err := db.Batch().Add("CAROL", 3).Commit(); err != nil || db.Get("carol") != 3 {
+		t.Errorf("batch keys were not normalized")
+	}
+}
+
+func TestDBCache_NormalizeKeys(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	db, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add("Alice", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.NormalizeKeys(LowerCase); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := db.TryGet("ALICE"); err != nil || !ok || value != 1 {
+		t.Errorf("TryGet(ALICE) = %d, %v, %v", value, ok, err)
+	}
+	reopened, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := reopened.TryGet("alice"); !ok {
+		t.Errorf("normalized key was not saved")
+	}
+}
+
+func TestNormalizeKeys_Restore(t *testing.T) {
+	from := New[int]().Add("Alice", 1)
+	to := New[int]().NormalizeKeys(LowerCase)
+	if err := Migrate[int](from, to); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := to.TryGet("alice"); !ok || value != 1 {
+		t.Errorf("migrated key was not normalized")
+	}
+
+	loaded := 0
+	to.Loader(func(ctx context.Context, key string) (int, error) {
+		loaded++
+		return len(key), nil
+	})
+	if err := to.Warm(context.Background(), []string{"ALICE", "Bob"}, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 1 || to.Get("bob") != 3 || to.Get("alice") != 1 {
+		t.Errorf("Warm missed normalized keys (loaded %d)", loaded)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	db := NewBytes().Grow(1024)
+	db.Add("a", []byte("first")).Add("b", []byte("second")).Add("a", []byte("third"))
+	for range 10 {
+		db.Add("c", bytes.Repeat([]byte{'c'}, 100))
+	}
+	db.Del("b")
+	if value := db.Get("a"); string(value) != "third" {
+		t.Errorf("Get(a) = %q", value)
+	}
+	if ok := db.GetFunc("c", func(value []byte) {
+		if len(value) != 100 {
+			t.Errorf("GetFunc(c) saw %d bytes", len(value))
+		}
+	}); !ok {
+		t.Errorf("GetFunc(c) missed")
+	}
+	if _, ok := db.TryGet("b"); ok || db.Len() != 2 {
+		t.Errorf("deleted key still stored")
+	}
+	if len(db.arena) > 2*(len("third")+100) {
+		t.Errorf("arena of %d bytes was not compacted", len(db.arena))
+	}
+
+	encoded := &bytes.Buffer{}
+	if _, err := db.WriteTo(encoded); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "cache.bin")
+	if err := os.WriteFile(filename, encoded.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := Fromf[[]byte](filename, NewBinaryEncoder, NewBinaryDecoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := cache.Get("a"); err != nil || string(value) != "third" {
+		t.Errorf("cache.Get(a) = %q, %v", value, err)
+	}
+
+	restored := NewBytes()
+	if n, err := restored.ReadFrom(encoded); err != nil || n == 0 || restored.Len() != 2 {
+		t.Errorf("ReadFrom = %d, %v with %d entries", n, err, restored.Len())
+	}
+	if _, err := NewBytes().ReadFrom(bytes.NewReader([]byte{2, 1})); !errors.Is(err, ErrBinary) {
+		t.Errorf("ReadFrom(truncated) = %v", err)
+	}
+}
+
+func TestPacked(t *testing.T) {
+	db := NewPacked[float64](4)
+	db.Add("a", 1).Add("b", 2).Add("c", 3).Add("a", 1.5).Del("a")
+	if _, ok := db.TryGet("a"); ok || db.Len() != 2 {
+		t.Errorf("deleted entry still stored")
+	}
+	if db.Get("c") != 3 || db.Get("b") != 2 {
+		t.Errorf("entry moved into the freed slot lost its value")
+	}
+
+	from := New[float64]().Add("x", 10).Add("y", 20)
+	if err := Migrate[float64](from, db); err != nil {
+		t.Fatal(err)
+	}
+	sum := 0.0
+	for _, value := range db.Seq2() {
+		sum += value
+	}
+	if sum != 35 {
+		t.Errorf("sum after migrating = %v", sum)
+	}
+
+	packed, expected := NewPacked[int](0), map[string]int{}
+	for i := range 20000 {
+		key := fmt.Sprint("key:", rand.N(1000))
+		if rand.N(3) == 0 {
+			packed.Del(key)
+			delete(expected, key)
+			continue
+		}
+		packed.Add(key, i)
+		expected[key] = i
+	}
+	if packed.Len() != len(expected) {
+		t.Errorf("packed.Len() = %d, expected %d", packed.Len(), len(expected))
+	}
+	for i := range 1000 {
+		key := fmt.Sprint("key:", i)
+		want, exists := expected[key]
+		if value, ok := packed.TryGet(key); value != want || ok != exists {
+			t.Fatalf("packed.TryGet(%q) = %d, %v, expected %d, %v", key, value, ok, want, exists)
+		}
+	}
+	if stored := maps.Collect(packed.Seq2()); !maps.Equal(stored, expected) {
+		t.Errorf("packed.Seq2() differs from the expected entries")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	db := New[int]()
+	fsys := &slowFS{MemFS: NewMemFS()}
+	cache, err := From[int]("cache.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys.creates.Store(0)
+
+	wg := sync.WaitGroup{}
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Update("counter", func(value int) int { return value + 1 })
+			if err := cache.Update("counter", func(value int) int { return value + 1 }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if value := db.Get("counter"); value != 20 {
+		t.Errorf("DB counter = %d", value)
+	}
+	if value, _ := cache.Get("counter"); value != 20 {
+		t.Errorf("DBCache counter = %d", value)
+	}
+	if n := fsys.creates.Load(); n > 20 {
+		t.Errorf("%d saves for 20 updates", n)
+	}
+}
+
+func TestBytes_Collisions(t *testing.T) {
+	db := NewBytes()
+	db.hash = func(string) uint64 { return 0 }
+	db.Add("a", []byte("1")).Add("b", []byte("2")).Add("c", []byte("3")).Add("b", []byte("22"))
+	if len(db.index) != 1 || len(db.collisions) != 2 {
+		t.Errorf("%d indexed, %d colliding", len(db.index), len(db.collisions))
+	}
+	db.Del("a")
+	for key, expected := range map[string]string{"b": "22", "c": "3"} {
+		if value := db.Get(key); string(value) != expected {
+			t.Errorf("Get(%s) = %q after its collision was removed", key, value)
+		}
+	}
+	if _, ok := db.TryGet("a"); ok || db.Len() != 2 {
+		t.Errorf("deleted colliding key still stored")
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	db := New[*sync.Mutex]()
+	cache, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	winners := atomic.Int32{}
+	actuals := make(chan *sync.Mutex, 10)
+	wg := sync.WaitGroup{}
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			actual, loaded := db.GetOrSet("singleton", &sync.Mutex{})
+			actuals <- actual
+			if _, cacheLoaded, err := cache.GetOrSet("singleton", fmt.Sprint(i)); err != nil {
+				t.Error(err)
+			} else if !cacheLoaded {
+				winners.Add(1)
+			}
+			if !loaded {
+				winners.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	close(actuals)
+	first := <-actuals
+	for actual := range actuals {
+		if actual != first {
+			t.Errorf("GetOrSet handed out different values")
+		}
+	}
+	if n := winners.Load(); n != 2 {
+		t.Errorf("%d GetOrSet calls stored their value", n)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	type record struct {
+		Version int
+		Tags    []string
+	}
+	db := New[record]().Add("record", record{Version: 1, Tags: []string{"a"}})
+	cache, err := From[int](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Add("counter", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	wins := atomic.Int32{}
+	wg := sync.WaitGroup{}
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if db.CompareAndSwap("record", record{Version: 1, Tags: []string{"a"}}, record{Version: 2}) {
+				wins.Add(1)
+			}
+			if swapped, err := cache.CompareAndSwap("counter", 0, i+1); err != nil {
+				t.Error(err)
+			} else if swapped {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := wins.Load(); n != 2 {
+		t.Errorf("%d CompareAndSwap calls won, expected one per store", n)
+	}
+	if db.CompareAndSwap("missing", record{}, record{Version: 1}) {
+		t.Errorf("CompareAndSwap stored a missing key")
+	}
+
+	db.Equal(func(a, b record) bool { return a.Version == b.Version })
+	if !db.CompareAndSwap("record", record{Version: 2, Tags: []string{"ignored"}}, record{Version: 3}) {
+		t.Errorf("CompareAndSwap ignored the equality function")
+	}
+	if db.Get("record").Version != 3 {
+		t.Errorf("unexpected record %v", db.Get("record"))
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	db := New[string]().Add("lease", "owner-1")
+	cache, err := From[string](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Add("lease", "owner-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.CompareAndDelete("lease", "owner-2") {
+		t.Errorf("CompareAndDelete released a lease held by someone else")
+	}
+	if deleted, err := cache.CompareAndDelete("lease", "owner-2"); err != nil || deleted {
+		t.Errorf("CompareAndDelete released a lease held by someone else: %v", err)
+	}
+	if !db.CompareAndDelete("lease", "owner-1") {
+		t.Errorf("CompareAndDelete kept the owned lease")
+	}
+	if deleted, err := cache.CompareAndDelete("lease", "owner-1"); err != nil || !deleted {
+		t.Errorf("CompareAndDelete kept the owned lease: %v", err)
+	}
+	if _, ok := db.TryGet("lease"); ok {
+		t.Errorf("lease still stored")
+	}
+	if _, ok, _ := cache.TryGet("lease"); ok {
+		t.Errorf("lease still stored")
+	}
+	if db.CompareAndDelete("lease", "owner-1") {
+		t.Errorf("CompareAndDelete reported deleting a missing key")
+	}
+}
+
+func TestWarnSlow(t *testing.T) {
+	var reported []string
+	db := New[int]().WarnSlow(5 * time.Millisecond).OnError(func(op string, key string, err error) {
+		if !errors.Is(err, ErrSlow) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		reported = append(reported, op+" "+key)
+	})
+
+	db.Add("fast", 1).Get("fast")
+	db.Update("slow", func(value int) int {
+		time.Sleep(10 * time.Millisecond)
+		return value + 1
+	})
+	if !slices.Equal(reported, []string{"update slow"}) {
+		t.Errorf("unexpected slow operations %v", reported)
+	}
+
+	db.WarnSlow(0).Update("slow", func(value int) int {
+		time.Sleep(10 * time.Millisecond)
+		return value + 1
+	})
+	if len(reported) != 1 {
+		t.Errorf("WarnSlow(0) still reported %v", reported)
+	}
+}
+
+func TestWithin(t *testing.T) {
+	db := New[int]()
+	cache, err := From[int](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddWithin("key", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddWithin("key", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := db.GetWithin("key", time.Millisecond); err != nil || !ok || value != 1 {
+		t.Errorf("GetWithin = %v, %v, %v", value, ok, err)
+	}
+	if value, ok, err := cache.GetWithin("key", time.Millisecond); err != nil || !ok || value != 1 {
+		t.Errorf("GetWithin = %v, %v, %v", value, ok, err)
+	}
+
+	db.mutex.Lock()
+	cache.mutex.Lock()
+	if _, _, err := db.GetWithin("key", 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if err := db.AddWithin("key", 2, 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if _, _, err := cache.GetWithin("key", 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if err := cache.AddWithin("key", 2, 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	time.AfterFunc(5*time.Millisecond, db.mutex.Unlock)
+	if err := db.AddWithin("key", 3, time.Second); err != nil {
+		t.Errorf("AddWithin gave up on a lock released in time: %v", err)
+	}
+	cache.mutex.Unlock()
+	if db.Get("key") != 3 {
+		t.Errorf("unexpected value %d", db.Get("key"))
+	}
+}
+
+func TestSwap(t *testing.T) {
+	db := New[int]()
+	cache, err := From[int](filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if previous, existed := db.Swap("key", 1); existed || previous != 0 {
+		t.Errorf("Swap on a missing key = %d, %v", previous, existed)
+	}
+	if previous, existed, err := cache.Swap("key", 1); err != nil || existed || previous != 0 {
+		t.Errorf("Swap on a missing key = %d, %v, %v", previous, existed, err)
+	}
+
+	sum, cacheSum := atomic.Int64{}, atomic.Int64{}
+	wg := sync.WaitGroup{}
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			previous, _ := db.Swap("key", i+2)
+			sum.Add(int64(previous))
+			previous, _, err := cache.Swap("key", i+2)
+			if err != nil {
+				t.Error(err)
+			}
+			cacheSum.Add(int64(previous))
+		}()
+	}
+	wg.Wait()
+	// Every value stored is handed back exactly once, by the next Swap or the final Get.
+	if total := sum.Load() + int64(db.Get("key")); total != 66 {
+		t.Errorf("Swap lost updates: values add up to %d", total)
+	}
+	if value, _ := cache.Get("key"); cacheSum.Load()+int64(value) != 66 {
+		t.Errorf("Swap lost updates: values add up to %d", cacheSum.Load()+int64(value))
+	}
+}
+
+func TestReadMostly(t *testing.T) {
+	flags := NewReadMostly[bool]().Add("dark-mode", true).Add("toggle", false).Add("beta", false).Del("beta")
+	if !flags.Get("dark-mode") || flags.Len() != 2 {
+		t.Errorf("unexpected flags %v", maps.Collect(flags.Seq2()))
+	}
+
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				flags.Add("toggle", i%2 == 0)
+			}
+		}
+	}()
+	for range 1000 {
+		if _, ok := flags.TryGet("dark-mode"); !ok {
+			t.Fatalf("reader saw a partially published map")
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if err := Migrate[bool](New[bool]().Add("new-checkout", true), flags); err != nil {
 		t.Fatal(err)
 	}
+	if !flags.Get("new-checkout") || flags.Len() != 3 {
+		t.Errorf("unexpected flags after migrating %v", maps.Collect(flags.Seq2()))
+	}
+}
 
-	db, err := From[*TestingUser]("testdata/cache_test.json")
+func TestPop(t *testing.T) {
+	db := New[int]()
+	cache, err := From[int](filepath.Join(t.TempDir(), "cache.json"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove("testdata/cache_test.json")
+	batch := cache.Batch()
+	for i := range 20 {
+		db.Add(fmt.Sprint(i), i)
+		batch.Add(fmt.Sprint(i), i)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
 
-	wg := &sync.WaitGroup{}
-	for i, key := range testKeys {
+	consumed := make([]atomic.Int32, 20)
+	wg := sync.WaitGroup{}
+	for range 4 {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := db.Add(key, &TestingUser{100 + i, key}); err != nil {
-				panic(err)
+			for i := range 20 {
+				if value, ok := db.Pop(fmt.Sprint(i)); ok {
+					consumed[value].Add(1)
+				}
+				if value, ok, err := cache.Pop(fmt.Sprint(i)); err != nil {
+					t.Error(err)
+				} else if ok {
+					consumed[value].Add(1)
+				}
 			}
 		}()
 	}
 	wg.Wait()
+	for i := range consumed {
+		if n := consumed[i].Load(); n != 2 {
+			t.Errorf("item %d consumed %d times from both stores", i, n)
+		}
+	}
+	if n, _ := cache.Len(); db.Len() != 0 || n != 0 {
+		t.Errorf("items left after popping everything")
+	}
+}
 
-	for _, key := range testKeys {
+func TestClear(t *testing.T) {
+	db := New[int]().Timeout(time.Hour).Guard(func(op Op, key string) error {
+		if op == OpDel && key == "locked" {
+			return errors.New("locked")
+		}
+		return nil
+	})
+	db.Add("a", 1).Add("b", 2).Add("locked", 3).Clear()
+	if db.Len() != 1 || db.Get("locked") != 3 {
+		t.Errorf("Clear left %v", db.KeysSnapshot())
+	}
+	db.mutex.Lock()
+	if pending := len(db.expiries.byKey); pending != 1 {
+		t.Errorf("%d expiration timers left after Clear", pending)
+	}
+	db.mutex.Unlock()
+
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Timeout(time.Hour)
+	for i := range 10 {
+		if err := cache.Add(fmt.Sprint(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := From[int](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := reopened.Len(); n != 0 {
+		t.Errorf("%d entries persisted after Clear", n)
+	}
+	cache.mutex.Lock()
+	if pending := len(cache.expiries.byKey); pending != 0 {
+		t.Errorf("%d expiration timers left after Clear", pending)
+	}
+	cache.mutex.Unlock()
+}
+
+func TestDB_Clone(t *testing.T) {
+	db := New[int]().Guard(func(op Op, key string) error {
+		if strings.HasPrefix(key, "secret/") {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	db.Add("a", 1).Add("b", 2).Add("secret/c", 3)
+
+	snapshot, clone := db.Snapshot(), db.Clone()
+	db.Add("a", 10).Del("b")
+	if !maps.Equal(snapshot, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("snapshot changed with the DB: %v", snapshot)
+	}
+	if !maps.Equal(clone.Snapshot(), snapshot) {
+		t.Errorf("clone %v != snapshot %v", clone.Snapshot(), snapshot)
+	}
+	clone.Add("d", 4)
+	if _, ok := db.TryGet("d"); ok {
+		t.Errorf("write to the clone reached the DB")
+	}
+}
+
+func TestSeen(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "seen.json")
+	db, err := From[time.Time](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firsts := atomic.Int32{}
+	wg := sync.WaitGroup{}
+	for range 10 {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := db.Del(key); err != nil {
-				panic(err)
+			if seen, err := Seen(db, "delivery-1", time.Hour); err != nil {
+				t.Error(err)
+			} else if !seen {
+				firsts.Add(1)
 			}
 		}()
 	}
 	wg.Wait()
+	if n := firsts.Load(); n != 1 {
+		t.Errorf("delivery processed %d times", n)
+	}
 
-	original := map[string]*TestingUser{}
-	originalData, _ := os.ReadFile("testdata/cache.json")
-	if err := json.Unmarshal(originalData, &original); err != nil {
+	restarted, err := From[time.Time](filename)
+	if err != nil {
 		t.Fatal(err)
 	}
-	afterTest := map[string]*TestingUser{}
-	afterData, _ := os.ReadFile("testdata/cache_test.json")
-	if err := json.Unmarshal(afterData, &afterTest); err != nil {
+	if seen, _ := Seen(restarted, "delivery-1", time.Hour); !seen {
+		t.Errorf("key forgotten after a restart")
+	}
+	if seen, _ := Seen(restarted, "short", time.Millisecond); seen {
+		t.Errorf("new key reported as seen")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if seen, _ := Seen(restarted, "short", time.Millisecond); seen {
+		t.Errorf("key reported as seen after its window closed")
+	}
+}
+
+func TestHas(t *testing.T) {
+	db := New[[1024]byte]().Add("a", [1024]byte{}).Add("b", [1024]byte{})
+	if !db.Has("a") || db.Has("c") || !db.ExistsAll("a", "b") || db.ExistsAll("a", "c") || !db.ExistsAll() {
+		t.Errorf("unexpected presence checks")
+	}
+	if stats := db.Stats(); stats.Hits+stats.Misses != 0 {
+		t.Errorf("presence checks counted as reads: %+v", stats)
+	}
+
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := From[lazyValue](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Add("a", lazyValue{V: 1}); err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := From[lazyValue](filename, WithLazyDecoding())
+	if err != nil {
 		t.Fatal(err)
 	}
+	lazyDecodes.Store(0)
+	if ok, err := lazy.Has("a"); err != nil || !ok {
+		t.Errorf("Has(a) = %v, %v", ok, err)
+	}
+	if ok, err := lazy.ExistsAll("a", "b"); err != nil || ok {
+		t.Errorf("ExistsAll(a, b) = %v, %v", ok, err)
+	}
+	if n := lazyDecodes.Load(); n != 0 {
+		t.Errorf("presence checks decoded %d values", n)
+	}
+}
 
-	if len(original) != len(afterTest) {
-		t.Errorf("len(original) != len(afterTest)")
+func TestDBCache_LogValue(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := From[lazyValue](filename)
+	if err != nil {
+		t.Fatal(err)
 	}
-	for key, value := range original {
-		if !reflect.DeepEqual(afterTest[key], value) {
-			t.Errorf("afterTest[%q] != %q (%q)", key, value, afterTest[key])
+	if err := cache.Add("a", lazyValue{V: 1}); err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := From[lazyValue](filename, WithLazyDecoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, stats := lazy.LogValue().String(), lazy.Stats(); stats.Len != 1 || !strings.Contains(value, "len=1 ") {
+		t.Errorf("LogValue() = %s, Stats().Len = %d", value, stats.Len)
+	}
+}
+
+func TestDB_AddRecurring(t *testing.T) {
+	cases := []struct {
+		schedule string
+		after    time.Time
+		next     time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 1, 10, 7, 30, 0, time.UTC), time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2026, 3, 6, 17, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 6 29 2 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 6, 30, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		next, err := parseSchedule(c.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := next(c.after); !got.Equal(c.next) {
+			t.Errorf("%q after %s = %s, expected %s", c.schedule, c.after, got, c.next)
 		}
 	}
 
-	for range 2 {
-		for _, value := range db.Seq2() {
-			if slices.Contains(testKeys, value.Name) {
-				t.Fatal("unexpected value in cache")
-			}
+	db := New[int]()
+	defer db.Shutdown(context.Background())
+	for _, schedule := range []string{"* * *", "60 * * * *", "*/0 * * * *", "@every -1s", "5-1 * * * *"} {
+		if err := db.AddRecurring("bad", schedule, func() int { return 0 }); !errors.Is(err, ErrSchedule) {
+			t.Errorf("schedule %q: expected ErrSchedule, got %v", schedule, err)
 		}
 	}
-}
 
-func TestDB_Timeout(t *testing.T) {
-	db := New[string]().Timeout(time.Millisecond * 100)
+	runs := atomic.Int32{}
+	if err := db.AddRecurring("ranking", "@every 5ms", func() int { return int(runs.Add(1)) }); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for db.Get("ranking") < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if db.Get("ranking") < 3 {
+		t.Fatalf("recurring entry produced %d times", runs.Load())
+	}
+	db.StopRecurring("ranking")
+	time.Sleep(10 * time.Millisecond)
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Errorf("entry still produced after StopRecurring")
+	}
 
-	db.Add("hello", "world")
-	for _, key := range testKeys {
-		db.Add(key, key)
+	if err := db.AddRecurring("ranking", "@every 5ms", func() int { return int(runs.Add(1)) }); err != nil {
+		t.Fatal(err)
 	}
+	for db.Get("ranking") <= int(stopped) && time.Now().Before(deadline.Add(time.Second)) {
+		time.Sleep(time.Millisecond)
+	}
+	db.Clear()
+	// A run already handed to the worker pool may still land, later ones must not.
+	time.Sleep(10 * time.Millisecond)
+	db.Del("ranking")
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := db.TryGet("ranking"); ok {
+		t.Errorf("cleared recurring entry came back")
+	}
+}
 
-	time.Sleep(time.Millisecond * 50)
-	db.Add("hello", "new_world")
-	time.Sleep(time.Millisecond * 60)
+func TestKeyed(t *testing.T) {
+	type userID int64
+	sessions := NewKeyed[userID, string]().Add(42, "alice").Add(-7, "bob").Add(1<<40, "carol").Del(-7)
+	sessions.DB().Add("not-a-number", "mallory")
 
-	if db.Len() != 1 {
-		t.Errorf("db.Len() != 1")
+	if sessions.Get(42) != "alice" || sessions.Has(-7) || !sessions.Has(1<<40) {
+		t.Errorf("unexpected sessions %v", sessions.DB().KeysSnapshot())
 	}
-	if db.Get("hello") != "new_world" {
-		t.Errorf("db.Get('hello') != \"new_world\"")
+	seen := map[userID]string{}
+	for id, name := range sessions.Seq2() {
+		seen[id] = name
+	}
+	if !maps.Equal(seen, map[userID]string{42: "alice", 1 << 40: "carol"}) {
+		t.Errorf("Seq2 = %v", seen)
+	}
+	if _, ok := parseKey[uint8]("300"); ok {
+		t.Errorf("out of range key parsed")
 	}
 }
 
-func TestDBCache_Timeout(t *testing.T) {
-	if err := exec.Command("cp", "testdata/cache.json", "testdata/cache_test.json").Run(); err != nil {
-		t.Fatal(err)
+func TestAppend(t *testing.T) {
+	db := New[string]().Add("7", "imported")
+	if id := db.Append("first"); id != 8 {
+		t.Errorf("Append after id 7 = %d", id)
+	}
+	db.Del("8")
+	if id := db.Append("second"); id != 9 {
+		t.Errorf("Append reused a deleted id: %d", id)
+	}
+	db.Add("10", "added")
+	if id := db.Append("third"); id != 11 || db.Get("10") != "added" {
+		t.Errorf("Append overwrote an added id: %d, %q", id, db.Get("10"))
+	}
+	if id := NewKeyed[uint64, string]().Append("event"); id != 1 {
+		t.Errorf("first Keyed.Append = %d", id)
 	}
 
-	db, err := From[*TestingUser]("testdata/cache_test.json")
+	filename := filepath.Join(t.TempDir(), "events.json")
+	events, err := From[string](filename)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove("testdata/cache_test.json")
+	ids := make(chan uint64, 10)
+	wg := sync.WaitGroup{}
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := events.Append(fmt.Sprint("event-", i))
+			if err != nil {
+				t.Error(err)
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	got := []uint64{}
+	for id := range ids {
+		got = append(got, id)
+	}
+	if slices.Sort(got); !slices.Equal(got, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("concurrent Append ids %v", got)
+	}
 
-	db.Timeout(time.Millisecond * 100)
-	time.Sleep(time.Millisecond * 50)
-	if err := db.Add("hello", &TestingUser{0, "new_world"}); err != nil {
+	if err := events.Del("10"); err != nil {
 		t.Fatal(err)
 	}
-	time.Sleep(time.Millisecond * 60)
+	restarted, err := From[string](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := restarted.Append("after restart"); err != nil || id != 11 {
+		t.Errorf("Append after a restart = %d, %v", id, err)
+	}
+	if err := restarted.Add("12", "added"); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := restarted.Append("after add"); err != nil || id != 13 {
+		t.Errorf("Append after adding id 12 = %d, %v", id, err)
+	}
+	if value, _ := restarted.Get("12"); value != "added" {
+		t.Errorf("Append overwrote an added id: %q", value)
+	}
+}
 
-	dblen, err := db.Len()
+func TestTransition(t *testing.T) {
+	type job struct {
+		Name  string
+		State State `nanodb:"state"`
+	}
+	transitions := Transitions{"pending": {"running"}, "running": {"done", "failed"}}
+	db := New[job]().Add("build", job{Name: "build", State: "pending"})
+	cache, err := From[*job](filepath.Join(t.TempDir(), "jobs.json"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if dblen != 1 {
-		t.Errorf("db.Len() != 1 (%d)", dblen)
+	queued := &job{Name: "deploy", State: "pending"}
+	if err := cache.Add("deploy", queued); err != nil {
+		t.Fatal(err)
 	}
 
-	if val, _ := db.Get("hello"); val.Name != "new_world" {
-		t.Errorf("db.Get('hello') != \"new_world\"")
+	wins := atomic.Int32{}
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if Transition(db, transitions, "build", "pending", "running") == nil {
+				wins.Add(1)
+			}
+			if Transition(cache, transitions, "deploy", "pending", "running") == nil {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := wins.Load(); n != 2 {
+		t.Errorf("%d racing transitions succeeded, expected one per store", n)
+	}
+	if state := db.Get("build").State; state != "running" {
+		t.Errorf("build is %q", state)
+	}
+	if deploy, _ := cache.Get("deploy"); deploy.State != "running" || queued.State != "pending" {
+		t.Errorf("deploy is %q, the stored pointer changed in place to %q", deploy.State, queued.State)
 	}
 
-	snapshot, _ := db.KeysSnapshot()
-	if len(snapshot) != 1 && snapshot[0] != "hello" {
-		t.Errorf("db.KeysSnapshot() != \"hello\"")
+	for _, err := range []error{
+		Transition(db, transitions, "build", "running", "pending"),
+		Transition(db, transitions, "build", "pending", "running"),
+		Transition(db, transitions, "missing", "running", "done"),
+		Transition(New[int](), transitions, "build", "running", "done"),
+	} {
+		if !errors.Is(err, ErrTransition) {
+			t.Errorf("expected ErrTransition, got %v", err)
+		}
+	}
+	if _, ok := db.TryGet("missing"); ok {
+		t.Errorf("failed transition created the entry")
+	}
+	if err := Transition(db, transitions, "build", "running", "done"); err != nil || db.Get("build").State != "done" {
+		t.Errorf("Transition to done = %v, build is %q", err, db.Get("build").State)
+	}
+}
+
+func TestTransition_Refused(t *testing.T) {
+	type job struct {
+		State State `nanodb:"state"`
+	}
+	transitions := Transitions{"pending": {"running"}}
+	db := New[job]().Timeout(time.Hour).Add("build", job{State: "running"})
+	observed := atomic.Int32{}
+	db.mutex.Lock()
+	db.addObserver(func([]change[job]) { observed.Add(1) })
+	lifetime := db.lifetimes["build"]
+	db.mutex.Unlock()
+
+	for _, key := range []string{"build", "missing"} {
+		if err := Transition(db, transitions, key, "pending", "running"); !errors.Is(err, ErrTransition) {
+			t.Errorf("expected ErrTransition for %q, got %v", key, err)
+		}
+	}
+	db.mutex.Lock()
+	renewed := !db.lifetimes["build"].Equal(lifetime)
+	db.mutex.Unlock()
+	if renewed || observed.Load() != 0 || db.Has("missing") {
+		t.Errorf("refused transitions wrote: lifetime renewed %v, %d observed changes", renewed, observed.Load())
+	}
+
+	fsys := &slowFS{MemFS: NewMemFS()}
+	cache, err := From[job]("jobs.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Add("build", job{State: "running"}); err != nil {
+		t.Fatal(err)
+	}
+	fsys.creates.Store(0)
+	if err := Transition(cache, transitions, "build", "pending", "running"); !errors.Is(err, ErrTransition) {
+		t.Errorf("expected ErrTransition, got %v", err)
+	}
+	if n := fsys.creates.Load(); n != 0 {
+		t.Errorf("refused transition saved %d times", n)
+	}
+}
+
+func TestSeen_HitDoesNotWrite(t *testing.T) {
+	fsys := &slowFS{MemFS: NewMemFS()}
+	db, err := From[time.Time]("seen.json", WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen, err := Seen(db, "delivery", time.Hour); err != nil || seen {
+		t.Fatalf("first Seen = %v, %v", seen, err)
+	}
+	closes, _ := db.Get("delivery")
+	fsys.creates.Store(0)
+	if seen, err := Seen(db, "delivery", 2*time.Hour); err != nil || !seen {
+		t.Fatalf("second Seen = %v, %v", seen, err)
+	}
+	if n := fsys.creates.Load(); n != 0 {
+		t.Errorf("duplicate check saved %d times", n)
+	}
+	if after, _ := db.Get("delivery"); !after.Equal(closes) {
+		t.Errorf("duplicate check moved the window from %s to %s", closes, after)
 	}
 }
 
@@ -321,6 +3395,94 @@ func BenchmarkDB_Cache_Parallel(b *testing.B) {
 	}
 }
 
+/*
+goos: linux
+goarch: amd64
+pkg: github.com/kittenbark/nanodb
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkHSet/DB/10         	 1435297	       881.2 ns/op	     616 B/op	       6 allocs/op
+BenchmarkHSet/DBCache/10    	  121190	      9768 ns/op	    3624 B/op	      55 allocs/op
+BenchmarkHSet/DB/1000       	   70863	     15814 ns/op	   54782 B/op	       9 allocs/op
+BenchmarkHSet/DBCache/1000  	    1963	    632679 ns/op	  237956 B/op	    2042 allocs/op
+*/
+func BenchmarkHSet(b *testing.B) {
+	for _, fields := range []int{10, 1000} {
+		b.Run(fmt.Sprint("DB/", fields), func(b *testing.B) {
+			db := New[map[string]int]()
+			for i := range fields {
+				if err := HSet(db, "hash", fmt.Sprint(i), i); err != nil {
+					b.Fatal(err)
+				}
+			}
+			for b.Loop() {
+				if err := HSet(db, "hash", fmt.Sprint(rand.N(fields)), 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprint("DBCache/", fields), func(b *testing.B) {
+			db, err := From[map[string]int]("cache.json", WithFS(NewMemFS()))
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := range fields {
+				if err := HSet(db, "hash", fmt.Sprint(i), i); err != nil {
+					b.Fatal(err)
+				}
+			}
+			for b.Loop() {
+				if err := HSet(db, "hash", fmt.Sprint(rand.N(fields)), 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+/*
+goos: linux
+goarch: amd64
+pkg: github.com/kittenbark/nanodb
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkPacked_Memory/map         	       3	 643201025 ns/op	        71.73 B/entry
+BenchmarkPacked_Memory/Packed      	       3	 406881146 ns/op	        35.84 B/entry
+*/
+func BenchmarkPacked_Memory(b *testing.B) {
+	const entries = 1_000_000
+	heap := func() int64 {
+		runtime.GC()
+		stats := runtime.MemStats{}
+		runtime.ReadMemStats(&stats)
+		return int64(stats.HeapAlloc)
+	}
+	measure := func(b *testing.B, build func() any) {
+		for b.Loop() {
+			before := heap()
+			db := build()
+			b.ReportMetric(float64(heap()-before)/entries, "B/entry")
+			runtime.KeepAlive(db)
+		}
+	}
+	b.Run("map", func(b *testing.B) {
+		measure(b, func() any {
+			db := make(map[string]float64)
+			for i := range entries {
+				db[fmt.Sprint("key:", i)] = float64(i)
+			}
+			return db
+		})
+	})
+	b.Run("Packed", func(b *testing.B) {
+		measure(b, func() any {
+			db := NewPacked[float64](0)
+			for i := range entries {
+				db.Add(fmt.Sprint("key:", i), float64(i))
+			}
+			return db
+		})
+	})
+}
+
 var testKeys = []string{
 	"green", "cyan", "blue", "red", "yellow", "purple", "orange", "pink",
 	"brown", "black", "white", "gray", "magenta", "violet", "indigo",
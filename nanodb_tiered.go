@@ -0,0 +1,117 @@
+package nanodb
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Tiered keeps at most limit recently used entries in an in-memory DB and spills the rest to a cold store,
+// an entry read from the cold store is promoted back to memory.
+type Tiered[T any] struct {
+	hot      *DB[T]
+	cold     Store[T]
+	limit    int
+	mutex    *sync.Mutex
+	recency  *list.List
+	elements map[string]*list.Element
+}
+
+func NewTiered[T any](hot *DB[T], cold Store[T], limit int) *Tiered[T] {
+	tiered := &Tiered[T]{
+		hot:      hot,
+		cold:     cold,
+		limit:    limit,
+		mutex:    &sync.Mutex{},
+		recency:  list.New(),
+		elements: make(map[string]*list.Element),
+	}
+	for _, key := range hot.KeysSnapshot() {
+		tiered.touch(key)
+	}
+	return tiered
+}
+
+func (tiered *Tiered[T]) TryGet(key string) (T, bool, error) {
+	tiered.mutex.Lock()
+	defer tiered.mutex.Unlock()
+
+	if value, ok := tiered.hot.TryGet(key); ok {
+		tiered.touch(key)
+		return value, true, nil
+	}
+	value, ok, err := tiered.cold.read(key)
+	if err != nil || !ok {
+		return value, ok, err
+	}
+
+	tiered.hot.Add(key, value)
+	tiered.touch(key)
+	return value, true, tiered.spill()
+}
+
+// Add writes to the hot tier and drops the cold copy, a write the hot tier refuses
+// is returned and leaves the cold copy in place.
+func (tiered *Tiered[T]) Add(key string, value T) error {
+	tiered.mutex.Lock()
+	defer tiered.mutex.Unlock()
+
+	if err := tiered.hot.TryAdd(key, value); err != nil {
+		return err
+	}
+	tiered.touch(key)
+	if err := tiered.forget(key); err != nil {
+		return err
+	}
+	return tiered.spill()
+}
+
+func (tiered *Tiered[T]) Del(key string) error {
+	tiered.mutex.Lock()
+	defer tiered.mutex.Unlock()
+
+	if err := tiered.hot.TryDel(key); err != nil {
+		return err
+	}
+	if element, ok := tiered.elements[key]; ok {
+		tiered.recency.Remove(element)
+		delete(tiered.elements, key)
+	}
+	return tiered.forget(key)
+}
+
+// touch has to be called with the lock held.
+func (tiered *Tiered[T]) touch(key string) {
+	if element, ok := tiered.elements[key]; ok {
+		tiered.recency.MoveToFront(element)
+		return
+	}
+	tiered.elements[key] = tiered.recency.PushFront(key)
+}
+
+// forget has to be called with the lock held, it drops a stale cold copy of key.
+func (tiered *Tiered[T]) forget(key string) error {
+	if _, ok, err := tiered.cold.read(key); err != nil || !ok {
+		return err
+	}
+	return tiered.cold.update(key, func(value T, _ bool) (T, Op) { return value, OpDel })
+}
+
+// spill has to be called with the lock held, it demotes the least recently used entries over the limit.
+func (tiered *Tiered[T]) spill() error {
+	for tiered.limit > 0 && len(tiered.elements) > tiered.limit {
+		element := tiered.recency.Back()
+		key := element.Value.(string)
+		tiered.recency.Remove(element)
+		delete(tiered.elements, key)
+
+		value, ok := tiered.hot.TryGet(key)
+		if !ok {
+			continue
+		}
+		if err := tiered.cold.update(key, func(T, bool) (T, Op) { return value, OpAdd }); err != nil {
+			return err
+		}
+		tiered.hot.Del(key)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package nanodb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// timers keeps at most one pending timer per key so rescheduling replaces instead of piling up,
+// and so Shutdown can stop them and wait for the ones already running.
+type timers struct {
+	lock    sync.Locker
+	byKey   map[string]*time.Timer
+	running *sync.WaitGroup
+	closed  bool
+}
+
+func newTimers(lock sync.Locker) *timers {
+	return &timers{lock: lock, byKey: make(map[string]*time.Timer), running: &sync.WaitGroup{}}
+}
+
+// schedule has to be called with the lock held, fn runs with the lock held.
+func (t *timers) schedule(key string, after time.Duration, fn func()) {
+	if t.closed {
+		return
+	}
+	t.cancel(key)
+
+	t.running.Add(1)
+	var timer *time.Timer
+	timer = time.AfterFunc(after, func() {
+		defer t.running.Done()
+
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		if t.closed || t.byKey[key] != timer {
+			return
+		}
+		delete(t.byKey, key)
+		fn()
+	})
+	t.byKey[key] = timer
+}
+
+// every has to be called with the lock held, fn runs with the lock held every interval until cancelled.
+func (t *timers) every(key string, interval time.Duration, fn func()) {
+	t.schedule(key, interval, func() {
+		fn()
+		t.every(key, interval, fn)
+	})
+}
+
+// cancel has to be called with the lock held.
+func (t *timers) cancel(key string) {
+	if timer, ok := t.byKey[key]; ok {
+		if timer.Stop() {
+			t.running.Done()
+		}
+		delete(t.byKey, key)
+	}
+}
+
+// close has to be called with the lock held.
+func (t *timers) close() {
+	for key := range t.byKey {
+		t.cancel(key)
+	}
+	t.closed = true
+}
+
+func (t *timers) wait(ctx context.Context) error {
+	return waitContext(ctx, t.running)
+}
+
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,82 @@
+package nanodb
+
+import "path"
+
+type EventKind int
+
+const (
+	EventAdded EventKind = iota
+	EventUpdated
+	EventDeleted
+)
+
+func (kind EventKind) String() string {
+	switch kind {
+	case EventAdded:
+		return "added"
+	case EventUpdated:
+		return "updated"
+	case EventDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+type Event[T any] struct {
+	Kind     EventKind
+	Key      string
+	Value    T
+	Previous T
+}
+
+// Trigger calls fn for every change of a key matching the path.Match pattern.
+// Callbacks run outside of the lock on the worker pool, so they may use the DB and may run out of order.
+func (db *DB[T]) Trigger(pattern string, fn func(event Event[T])) error {
+	_, err := db.Watch(pattern, fn)
+	return err
+}
+
+// Watch is Trigger returning a function that removes the callback again.
+func (db *DB[T]) Watch(pattern string, fn func(event Event[T])) (stop func(), err error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	pattern = db.normalized(pattern)
+	id := db.addObserver(func(changes []change[T]) {
+		events := make([]Event[T], 0, len(changes))
+		for _, change := range changes {
+			if matched, _ := path.Match(pattern, change.key); !matched {
+				continue
+			}
+
+			event := Event[T]{Key: change.key, Value: change.value, Previous: change.old}
+			switch {
+			case !change.exists:
+				event.Kind = EventDeleted
+			case change.existed:
+				event.Kind = EventUpdated
+			default:
+				event.Kind = EventAdded
+			}
+			events = append(events, event)
+		}
+		if len(events) == 0 {
+			return
+		}
+		db.workerPool().submit(func() {
+			for _, event := range events {
+				fn(event)
+			}
+		})
+	})
+	return func() {
+		db.mutex.Lock()
+		defer db.mutex.Unlock()
+		db.removeObserver(id)
+	}, nil
+}
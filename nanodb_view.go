@@ -0,0 +1,73 @@
+package nanodb
+
+import "sync"
+
+// View is an aggregate over every entry of a DB, maintained on each mutation.
+type View[T any, A any] struct {
+	db      *DB[T]
+	initial A
+	reduce  func(key string, value T, acc A) A
+	retract func(key string, value T, acc A) A
+	acc     A
+	dirty   bool
+	mutex   *sync.Mutex
+}
+
+// NewView folds every entry into initial with reduce. Inserts are applied incrementally,
+// updates and deletes mark the view for a full recomputation on the next Value call unless Retract is set.
+func NewView[T any, A any](db *DB[T], initial A, reduce func(key string, value T, acc A) A) *View[T, A] {
+	view := &View[T, A]{db: db, initial: initial, reduce: reduce, dirty: true, mutex: &sync.Mutex{}}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.addObserver(view.observe)
+	return view
+}
+
+// Retract sets the inverse of reduce so updates and deletes are applied incrementally too.
+func (view *View[T, A]) Retract(retract func(key string, value T, acc A) A) *View[T, A] {
+	view.db.mutex.Lock()
+	defer view.db.mutex.Unlock()
+
+	view.retract = retract
+	return view
+}
+
+func (view *View[T, A]) Value() A {
+	view.db.mutex.RLock()
+	defer view.db.mutex.RUnlock()
+
+	view.mutex.Lock()
+	defer view.mutex.Unlock()
+
+	if view.dirty {
+		view.acc = view.initial
+		for key, value := range view.db.data {
+			view.acc = view.reduce(key, value, view.acc)
+		}
+		view.dirty = false
+	}
+	return view.acc
+}
+
+func (view *View[T, A]) observe(changes []change[T]) {
+	view.mutex.Lock()
+	defer view.mutex.Unlock()
+
+	for _, change := range changes {
+		if view.dirty {
+			return
+		}
+		if change.existed {
+			if view.retract == nil {
+				view.dirty = true
+				return
+			}
+			view.acc = view.retract(change.key, change.old, view.acc)
+		}
+		if change.exists {
+			view.acc = view.reduce(change.key, change.value, view.acc)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package nanodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Loader[T any] func(ctx context.Context, key string) (T, error)
+
+func (db *DB[T]) Loader(loader Loader[T]) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.loader = loader
+	return db
+}
+
+func (db *DBCache[T, EncoderT, DecoderT]) Loader(loader Loader[T]) *DBCache[T, EncoderT, DecoderT] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.loader = loader
+	return db
+}
+
+var ErrNoLoader = errors.New("nanodb: no loader configured")
+
+// Warm loads the missing keys with the loader, at most concurrency at a time,
+// calling progress after each key. Failed keys are skipped and returned joined.
+// A key written while its value was loading keeps the written value.
+func (db *DB[T]) Warm(ctx context.Context, keys []string, concurrency int, progress func(done, total int)) error {
+	db.mutex.RLock()
+	loader := db.loader
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = db.normalized(key)
+		if _, ok := db.data[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	db.mutex.RUnlock()
+	if loader == nil {
+		return ErrNoLoader
+	}
+
+	return warm(ctx, missing, concurrency, loader, progress, func(key string, value T) error {
+		return db.update(key, func(current T, ok bool) (T, Op) {
+			if ok {
+				return current, OpGet
+			}
+			return value, OpAdd
+		})
+	})
+}
+
+// Warm loads the missing keys with the loader, at most concurrency at a time,
+// and saves them all at once.
+func (db *DBCache[T, EncoderT, DecoderT]) Warm(ctx context.Context, keys []string, concurrency int, progress func(done, total int)) error {
+	db.mutex.Lock()
+	loader := db.loader
+	err := db.load()
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = db.normalized(key)
+		if _, ok := db.data[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	db.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if loader == nil {
+		return ErrNoLoader
+	}
+
+	loaded := make(map[string]T, len(missing))
+	loadedMutex := &sync.Mutex{}
+	err = warm(ctx, missing, concurrency, loader, progress, func(key string, value T) error {
+		loadedMutex.Lock()
+		defer loadedMutex.Unlock()
+		loaded[key] = value
+		return nil
+	})
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if loadErr := db.loadForWrite(); loadErr != nil {
+		return errors.Join(err, loadErr)
+	}
+	if loadErr := db.materialize(); loadErr != nil {
+		return errors.Join(err, loadErr)
+	}
+	for key, value := range loaded {
+		if _, ok := db.data[key]; ok {
+			continue
+		}
+		db.set(key, value, time.Now())
+	}
+	return errors.Join(err, db.save())
+}
+
+func warm[T any](
+	ctx context.Context,
+	keys []string,
+	concurrency int,
+	loader Loader[T],
+	progress func(done, total int),
+	store func(key string, value T) error,
+) error {
+	concurrency = max(concurrency, 1)
+	semaphore := make(chan struct{}, concurrency)
+	done := atomic.Int64{}
+	errs := make([]error, len(keys))
+
+	wg := &sync.WaitGroup{}
+	for i, key := range keys {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return errors.Join(append(errs, ctx.Err())...)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			value, err := loader(ctx, key)
+			if err == nil {
+				err = store(key, value)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("nanodb: warm %q: %w", key, err)
+			}
+			if n := done.Add(1); progress != nil {
+				progress(int(n), len(keys))
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package nanodb
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrTimeout = errors.New("nanodb: lock not acquired in time")
+
+// GetWithin is TryGet failing with ErrTimeout when the lock is not acquired within timeout.
+func (db *DB[T]) GetWithin(key string, timeout time.Duration) (T, bool, error) {
+	defer db.traceSlow("get", key)()
+	if !acquire(db.mutex.TryRLock, timeout) {
+		var zero T
+		return zero, false, ErrTimeout
+	}
+	defer db.mutex.RUnlock()
+
+	value, ok := db.tryGet(key)
+	return value, ok, nil
+}
+
+// AddWithin is Add failing with ErrTimeout when the lock is not acquired within timeout.
+// Refused writes are reported as "add" like with Add.
+func (db *DB[T]) AddWithin(key string, value T, timeout time.Duration) error {
+	defer db.traceSlow("add", key)()
+	if !acquire(db.mutex.TryLock, timeout) {
+		return ErrTimeout
+	}
+	defer db.mutex.Unlock()
+
+	db.add(key, value)
+	return nil
+}
+
+// GetWithin is TryGet failing with ErrTimeout when the lock is not acquired within timeout.
+func (db *DBCache[T, EncoderT, DecoderT]) GetWithin(key string, timeout time.Duration) (T, bool, error) {
+	defer db.traceSlow("get", key)()
+	if !acquire(db.mutex.TryLock, timeout) {
+		var zero T
+		return zero, false, ErrTimeout
+	}
+	defer db.mutex.Unlock()
+
+	return db.tryGet(key)
+}
+
+// AddWithin is Add failing with ErrTimeout when the lock is not acquired within timeout,
+// the save itself is not bounded.
+func (db *DBCache[T, EncoderT, DecoderT]) AddWithin(key string, value T, timeout time.Duration) error {
+	defer db.traceSlow("add", key)()
+	if !acquire(db.mutex.TryLock, timeout) {
+		return ErrTimeout
+	}
+	defer db.mutex.Unlock()
+
+	return db.add(key, value)
+}
+
+// acquire retries tryLock with a growing pause until it succeeds or timeout passed.
+func acquire(tryLock func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for pause := 10 * time.Microsecond; !tryLock(); pause = min(pause*2, time.Millisecond) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		time.Sleep(min(pause, remaining))
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package nanodb
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+type xfetch[T any] struct {
+	beta      float64
+	recompute func(key string, value T) (T, error)
+	// deltas holds how long the last recompute of each key took, inflight the keys being recomputed.
+	deltas   *sync.Map
+	inflight *sync.Map
+}
+
+// XFetch recomputes entries early on reads near expiry with the probabilistic XFetch algorithm,
+// so recomputations spread out instead of piling up when a TTL ends. A read recomputes in the background
+// once now - delta*beta*ln(rand()) passes the expiry, where delta is how long the last recompute took
+// (1% of the TTL before the first one). beta 1 is the usual choice, higher recomputes earlier.
+func (db *DB[T]) XFetch(beta float64, recompute func(key string, value T) (T, error)) *DB[T] {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.workerPool()
+	db.xfetch = &xfetch[T]{beta: beta, recompute: recompute, deltas: &sync.Map{}, inflight: &sync.Map{}}
+	return db
+}
+
+// fetchEarly has to be called with the read lock held.
+func (db *DB[T]) fetchEarly(key string) {
+	x := db.xfetch
+	if x == nil || db.timeout == 0 {
+		return
+	}
+
+	ttl, lifetime := db.ttl(key), db.lifetimes[key]
+	delta := ttl / 100
+	if measured, ok := x.deltas.Load(key); ok {
+		delta = measured.(time.Duration)
+	}
+	early := time.Duration(-float64(delta) * x.beta * math.Log(rand.Float64()))
+	if time.Now().Add(early).Before(lifetime.Add(ttl)) {
+		return
+	}
+	if _, running := x.inflight.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	db.pool.submit(func() {
+		defer x.inflight.Delete(key)
+
+		start := time.Now()
+		db.refresh(key, lifetime, func(key string, value T) (T, error) {
+			defer func() { x.deltas.Store(key, time.Since(start)) }()
+			return x.recompute(key, value)
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package nanodb
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// SortedSet maps members to scores, use it with ZAdd, ZRange and friends.
+type SortedSet map[string]float64
+
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+func ZAdd(db Store[SortedSet], key string, member string, score float64) error {
+	return db.update(key, func(set SortedSet, _ bool) (SortedSet, Op) {
+		set = maps.Clone(set)
+		if set == nil {
+			set = SortedSet{}
+		}
+		set[member] = score
+		return set, OpAdd
+	})
+}
+
+func ZIncrBy(db Store[SortedSet], key string, member string, delta float64) (score float64, err error) {
+	err = db.update(key, func(set SortedSet, _ bool) (SortedSet, Op) {
+		set = maps.Clone(set)
+		if set == nil {
+			set = SortedSet{}
+		}
+		set[member] += delta
+		score = set[member]
+		return set, OpAdd
+	})
+	return
+}
+
+func ZRem(db Store[SortedSet], key string, member string) error {
+	return db.update(key, func(set SortedSet, _ bool) (SortedSet, Op) {
+		set = maps.Clone(set)
+		delete(set, member)
+		return set, addOrDel(len(set) > 0)
+	})
+}
+
+func ZScore(db Store[SortedSet], key string, member string) (float64, bool, error) {
+	set, _, err := db.read(key)
+	if err != nil {
+		return 0, false, err
+	}
+	score, ok := set[member]
+	return score, ok, nil
+}
+
+// ZRange returns members ranked from start to stop inclusive by ascending score,
+// negative indexes count from the end like in redis.
+func ZRange(db Store[SortedSet], key string, start, stop int) ([]ZMember, error) {
+	set, _, err := db.read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	members := set.sorted()
+	if start < 0 {
+		start += len(members)
+	}
+	if stop < 0 {
+		stop += len(members)
+	}
+	start, stop = max(start, 0), min(stop, len(members)-1)
+	if start > stop {
+		return []ZMember{}, nil
+	}
+	return members[start : stop+1], nil
+}
+
+// ZRank returns the zero-based position of member by ascending score.
+func ZRank(db Store[SortedSet], key string, member string) (int, bool, error) {
+	set, _, err := db.read(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if _, ok := set[member]; !ok {
+		return 0, false, nil
+	}
+
+	rank := slices.IndexFunc(set.sorted(), func(m ZMember) bool {
+		return m.Member == member
+	})
+	return rank, true, nil
+}
+
+func (set SortedSet) sorted() []ZMember {
+	members := make([]ZMember, 0, len(set))
+	for member, score := range set {
+		members = append(members, ZMember{Member: member, Score: score})
+	}
+	slices.SortFunc(members, func(a, b ZMember) int {
+		if c := cmp.Compare(a.Score, b.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Member, b.Member)
+	})
+	return members
+}
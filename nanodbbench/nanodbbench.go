@@ -0,0 +1,94 @@
+// Package nanodbbench compares nanodb.DB against other concurrent maps,
+// run it with `go test -bench . ./nanodbbench` before and after a performance change.
+package nanodbbench
+
+import (
+	"hash/maphash"
+	"sync"
+
+	"github.com/kittenbark/nanodb"
+)
+
+type Map interface {
+	Load(key string) (string, bool)
+	Store(key string, value string)
+	Delete(key string)
+}
+
+var Maps = map[string]func() Map{
+	"nanodb":  func() Map { return &DB{db: nanodb.New[string]()} },
+	"syncmap": func() Map { return &SyncMap{} },
+	"mutex":   func() Map { return NewMutexMap() },
+	"sharded": func() Map { return NewShardedMap(32) },
+}
+
+type DB struct {
+	db *nanodb.DB[string]
+}
+
+func (db *DB) Load(key string) (string, bool) { return db.db.TryGet(key) }
+func (db *DB) Store(key string, value string) { db.db.Add(key, value) }
+func (db *DB) Delete(key string)              { db.db.Del(key) }
+
+type SyncMap struct {
+	data sync.Map
+}
+
+func (m *SyncMap) Load(key string) (string, bool) {
+	value, ok := m.data.Load(key)
+	if !ok {
+		return "", false
+	}
+	return value.(string), true
+}
+func (m *SyncMap) Store(key string, value string) { m.data.Store(key, value) }
+func (m *SyncMap) Delete(key string)              { m.data.Delete(key) }
+
+type MutexMap struct {
+	data  map[string]string
+	mutex *sync.RWMutex
+}
+
+func NewMutexMap() *MutexMap {
+	return &MutexMap{data: make(map[string]string), mutex: &sync.RWMutex{}}
+}
+
+func (m *MutexMap) Load(key string) (string, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, ok := m.data[key]
+	return value, ok
+}
+
+func (m *MutexMap) Store(key string, value string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data[key] = value
+}
+
+func (m *MutexMap) Delete(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.data, key)
+}
+
+type ShardedMap struct {
+	shards []*MutexMap
+	seed   maphash.Seed
+}
+
+func NewShardedMap(shards int) *ShardedMap {
+	sharded := &ShardedMap{shards: make([]*MutexMap, shards), seed: maphash.MakeSeed()}
+	for i := range sharded.shards {
+		sharded.shards[i] = NewMutexMap()
+	}
+	return sharded
+}
+
+func (m *ShardedMap) Load(key string) (string, bool) { return m.shard(key).Load(key) }
+func (m *ShardedMap) Store(key string, value string) { m.shard(key).Store(key, value) }
+func (m *ShardedMap) Delete(key string)              { m.shard(key).Delete(key) }
+
+func (m *ShardedMap) shard(key string) *MutexMap {
+	return m.shards[maphash.String(m.seed, key)%uint64(len(m.shards))]
+}
@@ -0,0 +1,64 @@
+package nanodbbench
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestMaps(t *testing.T) {
+	for name, newMap := range Maps {
+		m := newMap()
+		m.Store("key", "value")
+		if value, ok := m.Load("key"); !ok || value != "value" {
+			t.Errorf("%s: Load(key) = %q, %v", name, value, ok)
+		}
+		m.Delete("key")
+		if _, ok := m.Load("key"); ok {
+			t.Errorf("%s: key survived Delete", name)
+		}
+	}
+}
+
+// BenchmarkMaps runs every map across read/write mixes and key cardinalities, e.g.
+// go test -bench 'Maps/reads=90%/keys=10000' -cpu 1,8 ./nanodbbench
+func BenchmarkMaps(b *testing.B) {
+	names := make([]string, 0, len(Maps))
+	for name := range Maps {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, reads := range []int{50, 90, 99} {
+		for _, cardinality := range []int{100, 10_000, 1_000_000} {
+			keys := make([]string, cardinality)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key-%d", i)
+			}
+			for _, name := range names {
+				b.Run(fmt.Sprintf("reads=%d%%/keys=%d/%s", reads, cardinality, name), func(b *testing.B) {
+					m := Maps[name]()
+					for _, key := range keys {
+						m.Store(key, key)
+					}
+					b.ResetTimer()
+
+					b.RunParallel(func(pb *testing.PB) {
+						for pb.Next() {
+							key := keys[rand.N(len(keys))]
+							switch op := rand.N(100); {
+							case op < reads:
+								m.Load(key)
+							case op%2 == 0:
+								m.Store(key, key)
+							default:
+								m.Delete(key)
+							}
+						}
+					})
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package nanodbconfig
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+type Store = nanodb.DBCache[json.RawMessage, *json.Encoder, *json.Decoder]
+
+// Config reads typed settings from a JSON object file kept by a DBCache, e.g. {"port": 8080, "timeout": "5s"}.
+// Getters fall back to the default when a key is missing or its value does not parse.
+type Config struct {
+	db        *Store
+	mutex     *sync.Mutex
+	env       string
+	requested map[string]struct{} // keys read through a getter, named in Watch by their env variables
+}
+
+// Open keeps the settings in filename, pass nanodb.WithFS and friends as opts.
+func Open(filename string, opts ...nanodb.Option) (*Config, error) {
+	db, err := nanodb.From[json.RawMessage](filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return New(db), nil
+}
+
+func New(db *Store) *Config {
+	return &Config{db: db, mutex: &sync.Mutex{}, requested: make(map[string]struct{})}
+}
+
+// Env makes environment variables override the file: key "http.read-timeout" is read
+// from PREFIX_HTTP_READ_TIMEOUT. Lists are comma separated.
+func (config *Config) Env(prefix string) *Config {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	config.env = prefix
+	return config
+}
+
+// Set stores value encoded as JSON.
+func (config *Config) Set(key string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return config.db.Add(key, raw)
+}
+
+func (config *Config) GetString(key string, otherwise string) string {
+	return get(config, key, otherwise, func(text string) (string, error) { return text, nil })
+}
+
+func (config *Config) GetInt(key string, otherwise int) int {
+	return get(config, key, otherwise, strconv.Atoi)
+}
+
+func (config *Config) GetBool(key string, otherwise bool) bool {
+	return get(config, key, otherwise, strconv.ParseBool)
+}
+
+func (config *Config) GetFloat(key string, otherwise float64) float64 {
+	return get(config, key, otherwise, func(text string) (float64, error) { return strconv.ParseFloat(text, 64) })
+}
+
+// GetDuration parses strings like "1m30s", plain numbers are nanoseconds.
+func (config *Config) GetDuration(key string, otherwise time.Duration) time.Duration {
+	return get(config, key, otherwise, time.ParseDuration)
+}
+
+func (config *Config) GetStringSlice(key string, otherwise []string) []string {
+	return get(config, key, otherwise, func(text string) ([]string, error) {
+		return strings.Split(text, ","), nil
+	})
+}
+
+// Watch checks the file and the environment every interval and calls fn with every key whose value changed,
+// appeared or disappeared since the previous check. A variable with the Env prefix that matches no key
+// in the file or read through a getter is reported as its name after the prefix, lowercased.
+func (config *Config) Watch(interval time.Duration, fn func(key string)) (stop func()) {
+	previous := config.snapshot()
+	done := make(chan struct{})
+	running := &sync.WaitGroup{}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := config.snapshot()
+				for key := range joinKeys(previous, current) {
+					if previous[key] != current[key] {
+						fn(key)
+					}
+				}
+				previous = current
+			}
+		}
+	}()
+	return sync.OnceFunc(func() {
+		close(done)
+		running.Wait()
+	})
+}
+
+// snapshot maps every key to its value text, overridden by the environment.
+func (config *Config) snapshot() map[string]string {
+	values := make(map[string]string)
+	for key, raw := range config.db.Seq2() {
+		values[key] = string(raw)
+	}
+
+	config.mutex.Lock()
+	prefix := config.env
+	known := make(map[string]string, len(values)+len(config.requested))
+	for key := range config.requested {
+		known[envName(prefix, key)] = key
+	}
+	config.mutex.Unlock()
+	if prefix == "" {
+		return values
+	}
+	for key := range values {
+		known[envName(prefix, key)] = key
+	}
+
+	for _, variable := range os.Environ() {
+		name, text, _ := strings.Cut(variable, "=")
+		rest, ok := strings.CutPrefix(name, prefix+"_")
+		if !ok {
+			continue
+		}
+		key, ok := known[name]
+		if !ok {
+			key = strings.ToLower(rest)
+		}
+		values[key] = text
+	}
+	return values
+}
+
+func (config *Config) lookupEnv(key string) (string, bool) {
+	config.mutex.Lock()
+	prefix := config.env
+	config.requested[key] = struct{}{}
+	config.mutex.Unlock()
+
+	if prefix == "" {
+		return "", false
+	}
+	return os.LookupEnv(envName(prefix, key))
+}
+
+func envName(prefix string, key string) string {
+	return prefix + "_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_", "/", "_").Replace(key))
+}
+
+// get prefers the environment, then the JSON value, then a JSON string parsed as environment text.
+func get[V any](config *Config, key string, otherwise V, parse func(text string) (V, error)) V {
+	if text, ok := config.lookupEnv(key); ok {
+		if value, err := parse(text); err == nil {
+			return value
+		}
+		return otherwise
+	}
+
+	raw, ok, err := config.db.TryGet(key)
+	if err != nil || !ok {
+		return otherwise
+	}
+	var value V
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return otherwise
+	}
+	if value, err := parse(text); err == nil {
+		return value
+	}
+	return otherwise
+}
+
+func joinKeys(a, b map[string]string) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range maps.Keys(a) {
+		keys[key] = struct{}{}
+	}
+	for key := range maps.Keys(b) {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
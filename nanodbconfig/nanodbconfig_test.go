@@ -0,0 +1,93 @@
+package nanodbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(filename, []byte(`{
+		"port": 8080,
+		"timeout": "5s",
+		"retries": "3",
+		"hosts": ["a", "b"],
+		"debug": true,
+		"broken": "not a number"
+	}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if port := config.GetInt("port", 80); port != 8080 {
+		t.Errorf("port = %d", port)
+	}
+	if timeout := config.GetDuration("timeout", time.Second); timeout != 5*time.Second {
+		t.Errorf("timeout = %s", timeout)
+	}
+	if retries := config.GetInt("retries", 0); retries != 3 {
+		t.Errorf("retries given as a string = %d", retries)
+	}
+	if hosts := config.GetStringSlice("hosts", nil); !slices.Equal(hosts, []string{"a", "b"}) {
+		t.Errorf("hosts = %v", hosts)
+	}
+	if !config.GetBool("debug", false) || config.GetInt("broken", 7) != 7 || config.GetString("missing", "x") != "x" {
+		t.Errorf("unexpected fallbacks")
+	}
+
+	t.Setenv("APP_PORT", "9090")
+	t.Setenv("APP_HOSTS", "c,d,e")
+	config.Env("APP")
+	if port := config.GetInt("port", 80); port != 9090 {
+		t.Errorf("port overridden by the environment = %d", port)
+	}
+	if hosts := config.GetStringSlice("hosts", nil); !slices.Equal(hosts, []string{"c", "d", "e"}) {
+		t.Errorf("hosts overridden by the environment = %v", hosts)
+	}
+}
+
+func TestConfig_Watch(t *testing.T) {
+	config, err := Open(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Set("level", "info"); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan string, 1)
+	stop := config.Watch(time.Millisecond, func(key string) { changed <- key })
+	defer stop()
+
+	if err := config.Set("level", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case key := <-changed:
+		if key != "level" || config.GetString("level", "") != "debug" {
+			t.Errorf("change of %q reported, level = %q", key, config.GetString("level", ""))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("change not reported")
+	}
+
+	config.GetDuration("http.read-timeout", time.Second)
+	config.Env("WATCHED")
+	for variable, want := range map[string]string{"WATCHED_HTTP_READ_TIMEOUT": "http.read-timeout", "WATCHED_DEBUG": "debug"} {
+		t.Setenv(variable, "on")
+		select {
+		case key := <-changed:
+			if key != want {
+				t.Errorf("env change of %q reported, expected %q", key, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("env-only key %q not reported", want)
+		}
+	}
+}
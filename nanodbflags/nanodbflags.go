@@ -0,0 +1,101 @@
+package nanodbflags
+
+import (
+	"hash/fnv"
+
+	"github.com/kittenbark/nanodb"
+)
+
+// Flag is stored under its name, a plain on/off flag only sets Enabled.
+type Flag struct {
+	Enabled bool `json:"enabled"`
+	// Rollout is the share of units, between 0 and 1, the flag is enabled for. 0 enables it for every unit.
+	Rollout float64 `json:"rollout,omitempty"`
+	// Variants split the units the flag is enabled for by weight.
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// Flags evaluates the flags of a DB. Units, users or sessions or anything else identified by a string,
+// land in the same bucket of a flag every time, so raising the rollout only ever adds units.
+type Flags struct {
+	db *nanodb.DB[Flag]
+}
+
+func New(db *nanodb.DB[Flag]) *Flags {
+	return &Flags{db: db}
+}
+
+func (flags *Flags) Set(name string, flag Flag) *Flags {
+	flags.db.Add(name, flag)
+	return flags
+}
+
+func (flags *Flags) Del(name string) *Flags {
+	flags.db.Del(name)
+	return flags
+}
+
+// Enabled reports whether the flag is enabled for unit, a missing flag is disabled.
+func (flags *Flags) Enabled(name string, unit string) bool {
+	flag, ok := flags.db.TryGet(name)
+	return ok && enabled(name, unit, flag)
+}
+
+// Variant picks the variant of unit, "" when the flag is disabled for it or has no variants.
+func (flags *Flags) Variant(name string, unit string) string {
+	flag, ok := flags.db.TryGet(name)
+	if !ok || !enabled(name, unit, flag) {
+		return ""
+	}
+
+	total := 0
+	for _, variant := range flag.Variants {
+		total += max(variant.Weight, 0)
+	}
+	if total == 0 {
+		return ""
+	}
+	point := int(bucket(name+"/variant", unit) * float64(total))
+	for _, variant := range flag.Variants {
+		if point -= max(variant.Weight, 0); point < 0 {
+			return variant.Name
+		}
+	}
+	return ""
+}
+
+// OnChange calls fn with every flag set or deleted, on the DB's worker pool.
+// Flag names containing "/" are not watched.
+func (flags *Flags) OnChange(fn func(name string, flag Flag, ok bool)) (stop func(), err error) {
+	return flags.db.Watch("*", func(event nanodb.Event[Flag]) {
+		fn(event.Key, event.Value, event.Kind != nanodb.EventDeleted)
+	})
+}
+
+func enabled(name string, unit string, flag Flag) bool {
+	return flag.Enabled && (flag.Rollout <= 0 || bucket(name, unit) < flag.Rollout)
+}
+
+// bucket hashes name and unit to a point in [0, 1).
+func bucket(name string, unit string) float64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(unit))
+	return float64(mix(hash.Sum64())>>11) / (1 << 53)
+}
+
+// mix spreads FNV's weak high bits, similar units would otherwise share buckets.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
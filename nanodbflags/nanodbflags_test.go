@@ -0,0 +1,91 @@
+package nanodbflags
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+func TestFlags(t *testing.T) {
+	flags := New(nanodb.New[Flag]()).
+		Set("dark-mode", Flag{Enabled: true}).
+		Set("killed", Flag{Enabled: false, Rollout: 1}).
+		Set("checkout", Flag{Enabled: true, Rollout: 0.25}).
+		Set("button", Flag{Enabled: true, Variants: []Variant{{Name: "red", Weight: 1}, {Name: "blue", Weight: 3}}})
+
+	if !flags.Enabled("dark-mode", "alice") || flags.Enabled("killed", "alice") || flags.Enabled("missing", "alice") {
+		t.Errorf("unexpected on/off flags")
+	}
+
+	enabled, blue := 0, 0
+	for i := range 10000 {
+		unit := fmt.Sprint("user-", i)
+		if flags.Enabled("checkout", unit) {
+			enabled++
+		}
+		if flags.Enabled("checkout", unit) != flags.Enabled("checkout", unit) {
+			t.Fatalf("bucketing of %s is not deterministic", unit)
+		}
+		if flags.Variant("button", unit) == "blue" {
+			blue++
+		}
+	}
+	if math.Abs(float64(enabled)/10000-0.25) > 0.02 {
+		t.Errorf("rollout of 0.25 enabled %d of 10000 units", enabled)
+	}
+	if math.Abs(float64(blue)/10000-0.75) > 0.02 {
+		t.Errorf("variant weighted 3 of 4 picked for %d of 10000 units", blue)
+	}
+
+	seen := 0
+	for i := range 1000 {
+		if flags.Enabled("checkout", fmt.Sprint("user-", i)) {
+			seen++
+		}
+	}
+	flags.Set("checkout", Flag{Enabled: true, Rollout: 0.5})
+	for i := range 1000 {
+		unit := fmt.Sprint("user-", i)
+		if flags.Enabled("checkout", unit) {
+			seen--
+		}
+	}
+	if seen > 0 {
+		t.Errorf("raising the rollout disabled units")
+	}
+}
+
+func TestFlags_OnChange(t *testing.T) {
+	flags := New(nanodb.New[Flag]())
+	changes := make(chan string, 2)
+	stop, err := flags.OnChange(func(name string, flag Flag, ok bool) {
+		changes <- fmt.Sprint(name, " ", flag.Enabled, " ", ok)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	steps := []struct {
+		apply    func()
+		expected string
+	}{
+		{func() { flags.Set("beta", Flag{Enabled: true}) }, "beta true true"},
+		{func() { flags.Del("beta") }, "beta false false"},
+	}
+	for _, step := range steps {
+		step.apply()
+		expected := step.expected
+		select {
+		case change := <-changes:
+			if change != expected {
+				t.Errorf("change %q != %q", change, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("change %q not delivered", expected)
+		}
+	}
+}
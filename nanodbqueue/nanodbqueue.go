@@ -0,0 +1,149 @@
+package nanodbqueue
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+var ErrNotFound = errors.New("nanodbqueue: job not found")
+
+type Job[T any] struct {
+	Key      string    `json:"key"`
+	Value    T         `json:"value"`
+	RunAt    time.Time `json:"run_at"`
+	Attempts int       `json:"attempts"`
+}
+
+type store[T any] interface {
+	tryGet(key string) (Job[T], bool, error)
+	add(key string, job Job[T]) error
+	del(key string) error
+	seq2() iter.Seq2[string, Job[T]]
+}
+
+// Queue is a delayed job table on top of a nanodb store.
+// Claimed jobs become visible again after the visibility timeout unless acknowledged.
+type Queue[T any] struct {
+	store      store[T]
+	visibility time.Duration
+	mutex      *sync.Mutex
+}
+
+func New[T any](db *nanodb.DB[Job[T]], visibility time.Duration) *Queue[T] {
+	return &Queue[T]{store: memoryStore[T]{db}, visibility: visibility, mutex: &sync.Mutex{}}
+}
+
+func NewCache[T any, EncoderT nanodb.Encoder, DecoderT nanodb.Decoder](
+	db *nanodb.DBCache[Job[T], EncoderT, DecoderT],
+	visibility time.Duration,
+) *Queue[T] {
+	return &Queue[T]{store: cacheStore[T, EncoderT, DecoderT]{db}, visibility: visibility, mutex: &sync.Mutex{}}
+}
+
+func (queue *Queue[T]) Enqueue(key string, value T, runAt time.Time) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	return queue.store.add(key, Job[T]{Key: key, Value: value, RunAt: runAt})
+}
+
+// Claim returns up to n due jobs, earliest first, and hides them for the visibility timeout.
+func (queue *Queue[T]) Claim(n int) ([]Job[T], error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	now := time.Now()
+	due := []Job[T]{}
+	for _, job := range queue.store.seq2() {
+		if !job.RunAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	slices.SortFunc(due, func(a, b Job[T]) int {
+		return a.RunAt.Compare(b.RunAt)
+	})
+	if len(due) > n {
+		due = due[:n]
+	}
+
+	for i := range due {
+		due[i].Attempts++
+		claimed := due[i]
+		claimed.RunAt = now.Add(queue.visibility)
+		if err := queue.store.add(claimed.Key, claimed); err != nil {
+			return nil, err
+		}
+	}
+	return due, nil
+}
+
+func (queue *Queue[T]) Ack(key string) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if _, ok, err := queue.store.tryGet(key); err != nil || !ok {
+		return errors.Join(err, ErrNotFound)
+	}
+	return queue.store.del(key)
+}
+
+// Nack makes a claimed job due again at retryAt.
+func (queue *Queue[T]) Nack(key string, retryAt time.Time) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	job, ok, err := queue.store.tryGet(key)
+	if err != nil || !ok {
+		return errors.Join(err, ErrNotFound)
+	}
+	job.RunAt = retryAt
+	return queue.store.add(key, job)
+}
+
+type memoryStore[T any] struct {
+	db *nanodb.DB[Job[T]]
+}
+
+func (store memoryStore[T]) tryGet(key string) (Job[T], bool, error) {
+	job, ok := store.db.TryGet(key)
+	return job, ok, nil
+}
+
+func (store memoryStore[T]) add(key string, job Job[T]) error {
+	store.db.Add(key, job)
+	return nil
+}
+
+func (store memoryStore[T]) del(key string) error {
+	store.db.Del(key)
+	return nil
+}
+
+func (store memoryStore[T]) seq2() iter.Seq2[string, Job[T]] {
+	return store.db.Seq2()
+}
+
+type cacheStore[T any, EncoderT nanodb.Encoder, DecoderT nanodb.Decoder] struct {
+	db *nanodb.DBCache[Job[T], EncoderT, DecoderT]
+}
+
+func (store cacheStore[T, EncoderT, DecoderT]) tryGet(key string) (Job[T], bool, error) {
+	return store.db.TryGet(key)
+}
+
+func (store cacheStore[T, EncoderT, DecoderT]) add(key string, job Job[T]) error {
+	return store.db.Add(key, job)
+}
+
+func (store cacheStore[T, EncoderT, DecoderT]) del(key string) error {
+	return store.db.Del(key)
+}
+
+func (store cacheStore[T, EncoderT, DecoderT]) seq2() iter.Seq2[string, Job[T]] {
+	return store.db.Seq2()
+}
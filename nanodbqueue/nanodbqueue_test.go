@@ -0,0 +1,75 @@
+package nanodbqueue
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+func TestQueue(t *testing.T) {
+	queue := New(nanodb.New[Job[string]](), time.Millisecond*50)
+	now := time.Now()
+
+	if err := queue.Enqueue("later", "later", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue("second", "second", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue("first", "first", now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := queue.Claim(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Key != "first" || jobs[1].Key != "second" {
+		t.Fatalf("queue.Claim(10) != [first second] (%+v)", jobs)
+	}
+	if again, _ := queue.Claim(10); len(again) != 0 {
+		t.Errorf("claimed jobs are visible (%+v)", again)
+	}
+
+	if err := queue.Ack("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Ack("first"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("queue.Ack('first') twice != ErrNotFound (%v)", err)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	jobs, _ = queue.Claim(10)
+	if len(jobs) != 1 || jobs[0].Key != "second" || jobs[0].Attempts != 2 {
+		t.Errorf("queue.Claim(10) after visibility timeout != [second (2 attempts)] (%+v)", jobs)
+	}
+}
+
+func TestQueue_Cache(t *testing.T) {
+	db, err := nanodb.From[Job[int]](filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := NewCache(db, time.Hour)
+
+	if err := queue.Enqueue("job", 42, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := queue.Claim(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Nack(jobs[0].Key, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = queue.Claim(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Value != 42 || jobs[0].Attempts != 2 {
+		t.Errorf("queue.Claim(1) after Nack != [42 (2 attempts)] (%+v)", jobs)
+	}
+}
@@ -0,0 +1,83 @@
+package nanodbtest
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+
+	"github.com/kittenbark/nanodb"
+)
+
+var ErrCrashed = errors.New("nanodbtest: simulated crash")
+
+// FaultFS wraps a nanodb.FS and cuts writes short after a byte budget,
+// leaving the torn prefix in the file like a crash or a full disk would.
+type FaultFS struct {
+	nanodb.FS
+	mutex  sync.Mutex
+	budget int64
+	err    error
+}
+
+func NewFaultFS(fsys nanodb.FS) *FaultFS {
+	return &FaultFS{FS: fsys, budget: -1}
+}
+
+// CrashAtByte lets n more bytes through, then fails every write with ErrCrashed.
+func (fsys *FaultFS) CrashAtByte(n int64) {
+	fsys.fail(n, ErrCrashed)
+}
+
+// NoSpace lets n more bytes through, then fails every write with ENOSPC.
+func (fsys *FaultFS) NoSpace(n int64) {
+	fsys.fail(n, syscall.ENOSPC)
+}
+
+func (fsys *FaultFS) Reset() {
+	fsys.fail(-1, nil)
+}
+
+func (fsys *FaultFS) Create(name string) (io.WriteCloser, error) {
+	file, err := fsys.FS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{WriteCloser: file, fsys: fsys}, nil
+}
+
+func (fsys *FaultFS) fail(budget int64, err error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	fsys.budget, fsys.err = budget, err
+}
+
+// allow reserves up to n bytes of the budget.
+func (fsys *FaultFS) allow(n int) (int, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	if fsys.budget < 0 || int64(n) <= fsys.budget {
+		if fsys.budget >= 0 {
+			fsys.budget -= int64(n)
+		}
+		return n, nil
+	}
+	allowed := int(fsys.budget)
+	fsys.budget = 0
+	return allowed, fsys.err
+}
+
+type faultFile struct {
+	io.WriteCloser
+	fsys *FaultFS
+}
+
+func (file *faultFile) Write(p []byte) (int, error) {
+	allowed, fault := file.fsys.allow(len(p))
+	n, err := file.WriteCloser.Write(p[:allowed])
+	if err != nil {
+		return n, err
+	}
+	return n, fault
+}
@@ -0,0 +1,194 @@
+// Package nanodbtest checks recorded histories of concurrent operations against the model of a map.
+package nanodbtest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Kind int
+
+const (
+	Get Kind = iota
+	Add
+	Del
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case Get:
+		return "get"
+	case Add:
+		return "add"
+	case Del:
+		return "del"
+	default:
+		return "unknown"
+	}
+}
+
+// Op is one completed operation, Value is the written value for Add and the observed one for Get.
+// Call and Return order operations in time, an op happened before another when its Return is less than the other's Call.
+type Op[T comparable] struct {
+	Kind   Kind
+	Key    string
+	Value  T
+	Found  bool
+	Call   int64
+	Return int64
+}
+
+func (op Op[T]) String() string {
+	switch op.Kind {
+	case Get:
+		return fmt.Sprintf("get(%s) = %v, %v [%d, %d]", op.Key, op.Value, op.Found, op.Call, op.Return)
+	case Add:
+		return fmt.Sprintf("add(%s, %v) [%d, %d]", op.Key, op.Value, op.Call, op.Return)
+	default:
+		return fmt.Sprintf("del(%s) [%d, %d]", op.Key, op.Call, op.Return)
+	}
+}
+
+// History records operations from many goroutines for CheckLinearizable.
+type History[T comparable] struct {
+	clock atomic.Int64
+	mutex sync.Mutex
+	ops   []Op[T]
+}
+
+func (history *History[T]) Get(key string, get func(key string) (T, bool)) (T, bool) {
+	call := history.clock.Add(1)
+	value, ok := get(key)
+	history.record(Op[T]{Kind: Get, Key: key, Value: value, Found: ok, Call: call, Return: history.clock.Add(1)})
+	return value, ok
+}
+
+func (history *History[T]) Add(key string, value T, add func(key string, value T)) {
+	call := history.clock.Add(1)
+	add(key, value)
+	history.record(Op[T]{Kind: Add, Key: key, Value: value, Call: call, Return: history.clock.Add(1)})
+}
+
+func (history *History[T]) Del(key string, del func(key string)) {
+	call := history.clock.Add(1)
+	del(key)
+	history.record(Op[T]{Kind: Del, Key: key, Call: call, Return: history.clock.Add(1)})
+}
+
+func (history *History[T]) Ops() []Op[T] {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	return slices.Clone(history.ops)
+}
+
+func (history *History[T]) record(op Op[T]) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	history.ops = append(history.ops, op)
+}
+
+// CheckLinearizable reports an error when no sequential order of ops, respecting real time,
+// explains every Get. Keys are independent, so each key is checked on its own.
+func CheckLinearizable[T comparable](ops []Op[T]) error {
+	byKey := make(map[string][]Op[T])
+	for _, op := range ops {
+		byKey[op.Key] = append(byKey[op.Key], op)
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		history := byKey[key]
+		slices.SortFunc(history, func(a, b Op[T]) int { return int(a.Call - b.Call) })
+		checker := &checker[T]{ops: history, done: make([]bool, len(history)), seen: make(map[state[T]]bool)}
+		var zero T
+		if !checker.search(zero, false, 0) {
+			return fmt.Errorf("nanodbtest: history of %q is not linearizable:\n%s", key, formatOps(history))
+		}
+	}
+	return nil
+}
+
+type checker[T comparable] struct {
+	ops  []Op[T]
+	done []bool
+	seen map[state[T]]bool
+}
+
+type state[T comparable] struct {
+	done    string
+	value   T
+	present bool
+}
+
+func (checker *checker[T]) search(value T, present bool, linearized int) bool {
+	if linearized == len(checker.ops) {
+		return true
+	}
+	current := state[T]{done: checker.doneSet(), value: value, present: present}
+	if checker.seen[current] {
+		return false
+	}
+	checker.seen[current] = true
+
+	for i, op := range checker.ops {
+		if checker.done[i] || !checker.minimal(op) {
+			continue
+		}
+
+		next, nextPresent := value, present
+		switch op.Kind {
+		case Get:
+			if op.Found != present || (present && op.Value != value) {
+				continue
+			}
+		case Add:
+			next, nextPresent = op.Value, true
+		case Del:
+			var zero T
+			next, nextPresent = zero, false
+		}
+
+		checker.done[i] = true
+		if checker.search(next, nextPresent, linearized+1) {
+			return true
+		}
+		checker.done[i] = false
+	}
+	return false
+}
+
+// minimal reports whether no pending op returned before op was called.
+func (checker *checker[T]) minimal(op Op[T]) bool {
+	for i, other := range checker.ops {
+		if !checker.done[i] && other.Return < op.Call {
+			return false
+		}
+	}
+	return true
+}
+
+func (checker *checker[T]) doneSet() string {
+	set := make([]byte, len(checker.done))
+	for i, done := range checker.done {
+		set[i] = '0'
+		if done {
+			set[i] = '1'
+		}
+	}
+	return string(set)
+}
+
+func formatOps[T comparable](ops []Op[T]) string {
+	lines := make([]string, len(ops))
+	for i, op := range ops {
+		lines[i] = "\t" + op.String()
+	}
+	return strings.Join(lines, "\n")
+}
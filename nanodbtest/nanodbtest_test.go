@@ -0,0 +1,139 @@
+package nanodbtest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/kittenbark/nanodb"
+)
+
+func TestCheckLinearizable(t *testing.T) {
+	ok := []Op[string]{
+		{Kind: Add, Key: "a", Value: "1", Call: 1, Return: 4},
+		{Kind: Get, Key: "a", Value: "", Found: false, Call: 2, Return: 3},
+		{Kind: Get, Key: "a", Value: "1", Found: true, Call: 5, Return: 6},
+	}
+	if err := CheckLinearizable(ok); err != nil {
+		t.Error(err)
+	}
+
+	stale := []Op[string]{
+		{Kind: Add, Key: "a", Value: "1", Call: 1, Return: 2},
+		{Kind: Get, Key: "a", Value: "", Found: false, Call: 3, Return: 4},
+	}
+	if err := CheckLinearizable(stale); err == nil {
+		t.Error("stale read accepted")
+	}
+}
+
+func TestDB_Linearizable(t *testing.T) {
+	db := nanodb.New[int]()
+	history := &History[int]{}
+	wg := &sync.WaitGroup{}
+	for worker := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 25 {
+				key := fmt.Sprint(rand.N(3))
+				switch rand.N(3) {
+				case 0:
+					history.Get(key, db.TryGet)
+				case 1:
+					history.Add(key, worker*100+i, func(key string, value int) { db.Add(key, value) })
+				case 2:
+					history.Del(key, func(key string) { db.Del(key) })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := CheckLinearizable(history.Ops()); err != nil {
+		t.Error(err)
+	}
+}
+
+// FuzzDBCache replays random operations against a DBCache and a plain map,
+// reopening the file at random points to simulate the process crashing between operations.
+func FuzzDBCache(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add([]byte{1, 1, 1, 3, 0, 0, 2, 2, 3, 1})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		filename := filepath.Join(t.TempDir(), "cache.json")
+		db, err := nanodb.From[int](filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model := make(map[string]int)
+
+		for i, op := range ops {
+			key := fmt.Sprint(op >> 2 % 8)
+			switch op % 4 {
+			case 0:
+				value, ok, err := db.TryGet(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected, expectedOk := model[key]; ok != expectedOk || value != expected {
+					t.Fatalf("op %d: get(%s) = %d, %v, model has %d, %v", i, key, value, ok, expected, expectedOk)
+				}
+			case 1:
+				if err := db.Add(key, i); err != nil {
+					t.Fatal(err)
+				}
+				model[key] = i
+			case 2:
+				if err := db.Del(key); err != nil {
+					t.Fatal(err)
+				}
+				delete(model, key)
+			case 3:
+				if db, err = nanodb.From[int](filename); err != nil {
+					t.Fatalf("op %d: reopen: %v", i, err)
+				}
+			}
+		}
+
+		if n, err := db.Len(); err != nil || n != len(model) {
+			t.Fatalf("len %d (%v), model has %d", n, err, len(model))
+		}
+	})
+}
+
+func TestFaultFS(t *testing.T) {
+	for _, fault := range []struct {
+		name   string
+		inject func(fsys *FaultFS)
+		err    error
+	}{
+		{"no space", func(fsys *FaultFS) { fsys.NoSpace(0) }, syscall.ENOSPC},
+		{"crash at byte 5", func(fsys *FaultFS) { fsys.CrashAtByte(5) }, ErrCrashed},
+	} {
+		t.Run(fault.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "cache.json")
+			fsys := NewFaultFS(nanodb.OSFS{})
+			db, err := nanodb.From[string](filename, nanodb.WithFS(fsys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Add("key", "value"); err != nil {
+				t.Fatal(err)
+			}
+
+			fault.inject(fsys)
+			if err := db.Add("other", "value"); !errors.Is(err, fault.err) {
+				t.Errorf("Add: %v, expected %v", err, fault.err)
+			}
+			fsys.Reset()
+			if _, err := nanodb.From[string](filename); err == nil {
+				t.Errorf("torn cache file opened without an error")
+			}
+		})
+	}
+}
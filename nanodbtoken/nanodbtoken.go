@@ -0,0 +1,159 @@
+package nanodbtoken
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+var ErrCorrupt = errors.New("nanodbtoken: token does not decrypt")
+
+const defaultAhead = time.Minute
+
+type Token struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// Cache keeps tokens encrypted with AES-GCM in a DBCache file until they expire.
+// Tokens are refreshed in the background once they are within the refresh-ahead window of expiring,
+// concurrent callers share a single fetch per key.
+type Cache struct {
+	db       *nanodb.DBCache[[]byte, *json.Encoder, *json.Decoder]
+	aead     cipher.AEAD
+	ahead    time.Duration
+	mutex    *sync.Mutex
+	inflight map[string]*call
+}
+
+type call struct {
+	done  chan struct{}
+	token Token
+	err   error
+}
+
+// Open keeps the tokens in filename, key is an AES-128, AES-192 or AES-256 key.
+func Open(filename string, key []byte, opts ...nanodb.Option) (*Cache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	db, err := nanodb.From[[]byte](filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{db: db, aead: aead, ahead: defaultAhead, mutex: &sync.Mutex{}, inflight: make(map[string]*call)}, nil
+}
+
+// Ahead sets how long before expiring a token is refreshed, a minute by default.
+func (cache *Cache) Ahead(ahead time.Duration) *Cache {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.ahead = ahead
+	return cache
+}
+
+// Token returns the token stored under key, fetching one when it is missing or expired.
+// A token within the refresh-ahead window is returned while a fresh one is fetched in the background,
+// a failed background fetch is logged and retried by the next call.
+func (cache *Cache) Token(key string, fetch func() (Token, error)) (Token, error) {
+	token, ok, err := cache.stored(key)
+	if err != nil {
+		return Token{}, err
+	}
+	remaining := time.Until(token.Expires)
+	if ok {
+		cache.mutex.Lock()
+		ahead := cache.ahead
+		cache.mutex.Unlock()
+		if remaining <= ahead {
+			go func() {
+				if _, err := cache.fetch(key, fetch); err != nil {
+					slog.Error("nanodbtoken", "refresh", key, "err", err)
+				}
+			}()
+		}
+		return token, nil
+	}
+	return cache.fetch(key, fetch)
+}
+
+// Del drops the token, e.g. once the server rejected it.
+func (cache *Cache) Del(key string) error {
+	return cache.db.Del(key)
+}
+
+// fetch runs fn once for all concurrent callers asking for key and stores the token.
+func (cache *Cache) fetch(key string, fn func() (Token, error)) (Token, error) {
+	cache.mutex.Lock()
+	if running, ok := cache.inflight[key]; ok {
+		cache.mutex.Unlock()
+		<-running.done
+		return running.token, running.err
+	}
+	running := &call{done: make(chan struct{})}
+	cache.inflight[key] = running
+	cache.mutex.Unlock()
+
+	running.token, running.err = fn()
+	if running.err == nil {
+		running.err = cache.store(key, running.token)
+	}
+
+	cache.mutex.Lock()
+	delete(cache.inflight, key)
+	cache.mutex.Unlock()
+	close(running.done)
+	return running.token, running.err
+}
+
+// stored returns the token under key, an expired one is deleted and reported missing
+// so the file does not keep every token ever fetched.
+func (cache *Cache) stored(key string) (Token, bool, error) {
+	sealed, ok, err := cache.db.TryGet(key)
+	if err != nil || !ok {
+		return Token{}, false, err
+	}
+	size := cache.aead.NonceSize()
+	if len(sealed) < size {
+		return Token{}, false, ErrCorrupt
+	}
+	plain, err := cache.aead.Open(nil, sealed[:size], sealed[size:], []byte(key))
+	if err != nil {
+		return Token{}, false, ErrCorrupt
+	}
+	token := Token{}
+	if err := json.Unmarshal(plain, &token); err != nil {
+		return Token{}, false, err
+	}
+	if time.Until(token.Expires) <= 0 {
+		_, err := cache.db.CompareAndDelete(key, sealed)
+		return Token{}, false, err
+	}
+	return token, true, nil
+}
+
+// store seals the token with key as additional data, so a token copied to another key does not decrypt.
+func (cache *Cache) store(key string, token Token) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, cache.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return cache.db.Add(key, cache.aead.Seal(nonce, nonce, plain, []byte(key)))
+}
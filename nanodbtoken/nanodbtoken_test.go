@@ -0,0 +1,115 @@
+package nanodbtoken
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func TestCache(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tokens.json")
+	cache, err := Open(filename, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetches := atomic.Int32{}
+	fetch := func() (Token, error) {
+		n := fetches.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return Token{Value: fmt.Sprint("secret-", n), Expires: time.Now().Add(time.Hour)}, nil
+	}
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := cache.Token("api", fetch); err != nil || token.Value != "secret-1" {
+				t.Errorf("Token = %+v, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("%d fetches for concurrent callers", n)
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("secret-1")) {
+		t.Errorf("token persisted in plain text: %s", raw)
+	}
+	reopened, err := Open(filename, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := reopened.Token("api", fetch); err != nil || token.Value != "secret-1" {
+		t.Errorf("reopened Token = %+v, %v", token, err)
+	}
+	other, err := Open(filename, bytes.Repeat([]byte{8}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Token("api", fetch); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt with the wrong key, got %v", err)
+	}
+}
+
+func TestCache_Ahead(t *testing.T) {
+	cache, err := Open(filepath.Join(t.TempDir(), "tokens.json"), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Ahead(time.Hour)
+
+	fetches := atomic.Int32{}
+	fetch := func() (Token, error) {
+		return Token{Value: fmt.Sprint(fetches.Add(1)), Expires: time.Now().Add(time.Minute)}, nil
+	}
+	if token, _ := cache.Token("api", fetch); token.Value != "1" {
+		t.Fatalf("unexpected first token %+v", token)
+	}
+	if token, _ := cache.Token("api", fetch); token.Value != "1" {
+		t.Errorf("expiring token not returned while refreshing: %+v", token)
+	}
+	deadline := time.Now().Add(time.Second)
+	for (fetches.Load() < 2 || !cache.idle()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if fetches.Load() < 2 {
+		t.Errorf("expiring token not refreshed ahead")
+	}
+
+	expired := func() (Token, error) { return Token{Value: "stale", Expires: time.Now().Add(-time.Second)}, nil }
+	if _, err := cache.Token("old", expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := cache.stored("old"); ok || err != nil {
+		t.Errorf("expired token reported stored (%v, %v)", ok, err)
+	}
+	if _, ok, _ := cache.db.TryGet("old"); ok {
+		t.Errorf("expired token kept in the file")
+	}
+	if _, err := cache.Token("old", expired); err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := cache.Token("old", fetch); token.Value == "stale" {
+		t.Errorf("expired token returned")
+	}
+}
+
+func (cache *Cache) idle() bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.inflight) == 0
+}
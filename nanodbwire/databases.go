@@ -0,0 +1,45 @@
+package nanodbwire
+
+import (
+	"errors"
+
+	"github.com/kittenbark/nanodb"
+)
+
+var ErrNoDatabases = errors.New("nanodbwire: server hosts a single database")
+
+// Databases lets clients switch to named databases with Use. open is called once per name,
+// on first use, and configures the database (timeouts, quotas, guards) or rejects the name.
+func (server *Server) Databases(open func(name string) (*nanodb.DB[[]byte], error)) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.opener = open
+	server.databases = make(map[string]*nanodb.DB[[]byte])
+	return server
+}
+
+func (server *Server) open(name string) (*nanodb.DB[[]byte], error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.opener == nil {
+		return nil, ErrNoDatabases
+	}
+	if db, ok := server.databases[name]; ok {
+		return db, nil
+	}
+	db, err := server.opener(name)
+	if err != nil {
+		return nil, err
+	}
+	server.databases[name] = db
+	return db, nil
+}
+
+// database returns a database open already succeeded for.
+func (server *Server) database(name string) *nanodb.DB[[]byte] {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.databases[name]
+}
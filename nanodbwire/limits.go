@@ -0,0 +1,84 @@
+package nanodbwire
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"time"
+)
+
+// limits is the configuration a connection is served with, copied when Serve starts.
+type limits struct {
+	tls       *tls.Config
+	maxConns  int
+	perSecond float64
+	burst     int
+	idle      time.Duration
+	logger    *slog.Logger
+	audit     func(record AuditRecord)
+}
+
+// TLS serves every listener passed to Serve afterwards over TLS.
+func (server *Server) TLS(config *tls.Config) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.limits.tls = config
+	return server
+}
+
+// MaxConns closes new connections once n are open, 0 means no limit.
+func (server *Server) MaxConns(n int) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.limits.maxConns = n
+	return server
+}
+
+// RateLimit delays requests beyond perSecond on a connection, allowing bursts of burst requests.
+func (server *Server) RateLimit(perSecond float64, burst int) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.limits.perSecond, server.limits.burst = perSecond, max(burst, 1)
+	return server
+}
+
+// IdleTimeout closes connections that send no request for d, watchers excepted.
+func (server *Server) IdleTimeout(d time.Duration) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.limits.idle = d
+	return server
+}
+
+func (limits limits) bucket() *bucket {
+	if limits.perSecond <= 0 {
+		return nil
+	}
+	return &bucket{perSecond: limits.perSecond, burst: float64(limits.burst), tokens: float64(limits.burst), last: time.Now()}
+}
+
+// bucket is a token bucket owned by a single connection.
+type bucket struct {
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+func (b *bucket) wait() {
+	if b == nil {
+		return
+	}
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		time.Sleep(time.Duration((1 - b.tokens) / b.perSecond * float64(time.Second)))
+		b.tokens, b.last = 1, time.Now()
+	}
+	b.tokens--
+}
@@ -0,0 +1,101 @@
+package nanodbwire
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// AuditRecord describes a mutating request, Caller is the client certificate subject over TLS
+// and the remote address otherwise.
+type AuditRecord struct {
+	Time   time.Time
+	Caller string
+	Method string
+	Key    string
+}
+
+// Logger logs every request with its method, key, latency and outcome at debug level,
+// failed requests at warn level.
+func (server *Server) Logger(logger *slog.Logger) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.limits.logger = logger
+	return server
+}
+
+// Audit calls record for every set and del request, synchronously before the response is sent.
+func (server *Server) Audit(record func(record AuditRecord)) *Server {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.limits.audit = record
+	return server
+}
+
+func (server *Server) observe(conn net.Conn, limits limits, head byte, fields [][]byte, began time.Time, status byte) {
+	if limits.logger == nil && limits.audit == nil {
+		return
+	}
+
+	method, key := methodOf(head), ""
+	if len(fields) > 0 {
+		key = string(fields[0])
+	}
+	if limits.logger != nil {
+		level := slog.LevelDebug
+		if status == statusError {
+			level = slog.LevelWarn
+		}
+		limits.logger.LogAttrs(context.Background(), level, "nanodbwire request",
+			slog.String("method", method),
+			slog.String("key", key),
+			slog.Duration("latency", time.Since(began)),
+			slog.String("outcome", outcomeOf(status)),
+			slog.String("remote", conn.RemoteAddr().String()),
+		)
+	}
+	if limits.audit != nil && (head == opSet || head == opDel) {
+		limits.audit(AuditRecord{Time: began, Caller: callerOf(conn), Method: method, Key: key})
+	}
+}
+
+func callerOf(conn net.Conn) string {
+	if conn, ok := conn.(*tls.Conn); ok {
+		if peers := conn.ConnectionState().PeerCertificates; len(peers) > 0 {
+			return peers[0].Subject.String()
+		}
+	}
+	return conn.RemoteAddr().String()
+}
+
+func methodOf(head byte) string {
+	switch head {
+	case opGet:
+		return "get"
+	case opSet:
+		return "set"
+	case opDel:
+		return "del"
+	case opWatch:
+		return "watch"
+	case opUse:
+		return "use"
+	default:
+		return "unknown"
+	}
+}
+
+func outcomeOf(status byte) string {
+	switch status {
+	case statusOK:
+		return "ok"
+	case statusNotFound:
+		return "not_found"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,133 @@
+package nanodbwire
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+// mirrorRetry is how long a Mirror waits before watching again after the watch connection failed.
+const mirrorRetry = time.Second
+
+// Mirror caches the values of keys matching a path.Match pattern in a local nanodb.DB,
+// kept current by the server's change stream. Other keys, and every key while the change stream
+// is down, are read from the server.
+type Mirror struct {
+	client  *Client
+	pattern string
+	local   *nanodb.DB[[]byte]
+	mutex   *sync.Mutex
+	// watching is set while the change stream is up, changes counts its events.
+	watching bool
+	changes  uint64
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewMirror(client *Client, pattern string) (*Mirror, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mirror := &Mirror{
+		client:  client,
+		pattern: pattern,
+		local:   nanodb.New[[]byte](),
+		mutex:   &sync.Mutex{},
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go mirror.follow(ctx)
+	return mirror, nil
+}
+
+func (mirror *Mirror) Get(key string) ([]byte, bool, error) {
+	mirror.mutex.Lock()
+	cached, changes := mirror.watching, mirror.changes
+	if value, ok := mirror.local.TryGet(key); ok && cached {
+		mirror.mutex.Unlock()
+		return value, true, nil
+	}
+	mirror.mutex.Unlock()
+
+	value, ok, err := mirror.client.Get(key)
+	if err != nil || !ok {
+		return value, ok, err
+	}
+
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	// Any change in between may have been to key, so the fetched value could already be stale.
+	if cached && mirror.watching && mirror.changes == changes && mirror.matches(key) {
+		mirror.local.Add(key, value)
+	}
+	return value, true, nil
+}
+
+func (mirror *Mirror) Set(key string, value []byte) error {
+	defer mirror.invalidate(key)
+	return mirror.client.Set(key, value)
+}
+
+func (mirror *Mirror) Del(key string) error {
+	defer mirror.invalidate(key)
+	return mirror.client.Del(key)
+}
+
+// Close stops following the change stream, the client stays open.
+func (mirror *Mirror) Close() error {
+	mirror.cancel()
+	<-mirror.done
+	return nil
+}
+
+func (mirror *Mirror) follow(ctx context.Context) {
+	defer close(mirror.done)
+
+	for ctx.Err() == nil {
+		_ = mirror.client.watch(ctx, mirror.pattern, mirror.start, mirror.apply)
+		mirror.stop()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(mirrorRetry):
+		}
+	}
+}
+
+func (mirror *Mirror) start() {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+	mirror.watching = true
+}
+
+// stop drops the cache, changes made while the stream is down would go unnoticed.
+func (mirror *Mirror) stop() {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	mirror.watching = false
+	mirror.local = nanodb.New[[]byte]()
+}
+
+func (mirror *Mirror) apply(event Event) {
+	mirror.invalidate(event.Key)
+}
+
+func (mirror *Mirror) invalidate(key string) {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	mirror.changes++
+	mirror.local.Del(key)
+}
+
+func (mirror *Mirror) matches(key string) bool {
+	matched, _ := path.Match(mirror.pattern, key)
+	return matched
+}
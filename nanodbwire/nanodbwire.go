@@ -0,0 +1,510 @@
+// Package nanodbwire serves a nanodb.DB over TCP or Unix sockets with a small length-prefixed binary protocol.
+//
+// Every message is a frame: a big-endian uint32 length followed by that many bytes.
+// A frame starts with a head byte followed by fields, each a big-endian uint32 length and the bytes.
+// Requests have an op head:
+//
+//	get key | set key value | del key | watch pattern | use database
+//
+// Responses have a status head and a single field, the value for get or the message for StatusError.
+// Once a watch is acknowledged the server only sends event frames on that connection,
+// with a nanodb.EventKind head and the key and value fields.
+package nanodbwire
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+const (
+	opGet byte = iota + 1
+	opSet
+	opDel
+	opWatch
+	opUse
+)
+
+const (
+	statusOK byte = iota
+	statusNotFound
+	statusError
+)
+
+// maxFrame bounds the memory a single frame may claim.
+const maxFrame = 64 << 20
+
+// watchBuffer is how many events a watcher may fall behind before its connection is closed.
+const watchBuffer = 256
+
+var (
+	ErrServerClosed  = errors.New("nanodbwire: server closed")
+	ErrFrameTooLarge = errors.New("nanodbwire: frame too large")
+	ErrMalformed     = errors.New("nanodbwire: malformed frame")
+)
+
+// RemoteError is an error returned by the server.
+type RemoteError struct {
+	Message string
+}
+
+func (err *RemoteError) Error() string {
+	return "nanodbwire: remote: " + err.Message
+}
+
+type Event struct {
+	Kind  nanodb.EventKind
+	Key   string
+	Value []byte
+}
+
+type Server struct {
+	db        *nanodb.DB[[]byte]
+	mutex     *sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	running   *sync.WaitGroup
+	limits    limits
+	opener    func(name string) (*nanodb.DB[[]byte], error)
+	databases map[string]*nanodb.DB[[]byte]
+}
+
+func NewServer(db *nanodb.DB[[]byte]) *Server {
+	return &Server{
+		db:        db,
+		mutex:     &sync.Mutex{},
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		running:   &sync.WaitGroup{},
+	}
+}
+
+// Serve accepts connections until listener fails or the server is closed, then it returns ErrServerClosed.
+func (server *Server) Serve(listener net.Listener) error {
+	server.mutex.Lock()
+	limits := server.limits
+	server.mutex.Unlock()
+	if limits.tls != nil {
+		listener = tls.NewListener(listener, limits.tls)
+	}
+
+	if !track(server, server.listeners, listener) {
+		return ErrServerClosed
+	}
+	defer untrack(server, server.listeners, listener)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if server.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !track(server, server.conns, conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		// Limits other than TLS apply to new connections as soon as they change.
+		server.mutex.Lock()
+		limits := server.limits
+		server.mutex.Unlock()
+		if limits.maxConns > 0 && server.connections() > limits.maxConns {
+			untrack(server, server.conns, conn)
+			conn.Close()
+			continue
+		}
+
+		server.running.Add(1)
+		go func() {
+			defer server.running.Done()
+			defer untrack(server, server.conns, conn)
+			defer conn.Close()
+			server.handle(conn, limits)
+		}()
+	}
+}
+
+// Shutdown stops every listener and lets connections finish the request in progress,
+// then waits for their handlers or closes them once ctx is done.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mutex.Lock()
+	server.closed = true
+	errs := []error{}
+	for listener := range server.listeners {
+		errs = append(errs, listener.Close())
+	}
+	for conn := range server.conns {
+		// Handlers waiting for the next request give up, the ones serving one finish it first.
+		errs = append(errs, conn.SetReadDeadline(time.Now()))
+	}
+	server.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		server.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return errors.Join(errs...)
+	case <-ctx.Done():
+		server.mutex.Lock()
+		for conn := range server.conns {
+			conn.Close()
+		}
+		server.mutex.Unlock()
+		<-drained
+		return errors.Join(append(errs, ctx.Err())...)
+	}
+}
+
+// Close stops every listener, closes every connection and waits for their handlers.
+func (server *Server) Close() error {
+	server.mutex.Lock()
+	server.closed = true
+	errs := []error{}
+	for listener := range server.listeners {
+		errs = append(errs, listener.Close())
+	}
+	for conn := range server.conns {
+		errs = append(errs, conn.Close())
+	}
+	server.mutex.Unlock()
+
+	server.running.Wait()
+	return errors.Join(errs...)
+}
+
+func (server *Server) handle(conn net.Conn, limits limits) {
+	reader, writer := bufio.NewReader(conn), bufio.NewWriter(conn)
+	bucket, db := limits.bucket(), server.db
+	for !server.isClosed() {
+		if limits.idle > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(limits.idle))
+		}
+		head, fields, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+		bucket.wait()
+
+		if head == opWatch && len(fields) == 1 {
+			// A watcher legitimately sends nothing, so it is exempt from the idle timeout.
+			_ = conn.SetReadDeadline(time.Time{})
+			server.observe(conn, limits, head, fields, time.Now(), statusOK)
+			server.watch(db, reader, writer, string(fields[0]))
+			return
+		}
+
+		began := time.Now()
+		status, value := server.respond(db, head, fields)
+		if head == opUse && status == statusOK {
+			db, value = server.database(string(fields[0])), nil
+		}
+		server.observe(conn, limits, head, fields, began, status)
+		if err := writeFrame(writer, status, value); err != nil {
+			return
+		}
+	}
+}
+
+func (server *Server) respond(db *nanodb.DB[[]byte], head byte, fields [][]byte) (status byte, value []byte) {
+	switch {
+	case head == opGet && len(fields) == 1:
+		if value, ok := db.TryGet(string(fields[0])); ok {
+			return statusOK, value
+		}
+		return statusNotFound, nil
+	case head == opSet && len(fields) == 2:
+		if err := db.TryAdd(string(fields[0]), fields[1]); err != nil {
+			return statusError, []byte(err.Error())
+		}
+		return statusOK, nil
+	case head == opDel && len(fields) == 1:
+		if err := db.TryDel(string(fields[0])); err != nil {
+			return statusError, []byte(err.Error())
+		}
+		return statusOK, nil
+	case head == opUse && len(fields) == 1:
+		if _, err := server.open(string(fields[0])); err != nil {
+			return statusError, []byte(err.Error())
+		}
+		return statusOK, nil
+	default:
+		return statusError, []byte(fmt.Sprintf("unknown request %d with %d fields", head, len(fields)))
+	}
+}
+
+func (server *Server) watch(db *nanodb.DB[[]byte], reader io.Reader, writer *bufio.Writer, pattern string) {
+	events := make(chan nanodb.Event[[]byte], watchBuffer)
+	overflow := make(chan struct{})
+	once := &sync.Once{}
+	stop, err := db.Watch(pattern, func(event nanodb.Event[[]byte]) {
+		select {
+		case events <- event:
+		default:
+			once.Do(func() { close(overflow) })
+		}
+	})
+	if err != nil {
+		_ = writeFrame(writer, statusError, []byte(err.Error()))
+		return
+	}
+	defer stop()
+	if err := writeFrame(writer, statusOK, nil); err != nil {
+		return
+	}
+
+	// The client sends nothing after watch, reading only notices it hanging up.
+	hangup := make(chan struct{})
+	go func() {
+		defer close(hangup)
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if err := writeFrame(writer, byte(event.Kind), []byte(event.Key), event.Value); err != nil {
+				return
+			}
+		case <-overflow:
+			return
+		case <-hangup:
+			return
+		}
+	}
+}
+
+func track[K comparable](server *Server, set map[K]struct{}, value K) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.closed {
+		return false
+	}
+	set[value] = struct{}{}
+	return true
+}
+
+func untrack[K comparable](server *Server, set map[K]struct{}, value K) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	delete(set, value)
+}
+
+func (server *Server) connections() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.conns)
+}
+
+func (server *Server) isClosed() bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.closed
+}
+
+// Client is safe for concurrent use, requests on one client are sent one at a time.
+type Client struct {
+	dial   func(ctx context.Context) (net.Conn, error)
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	mutex  *sync.Mutex
+	// database is the name passed to Use, watch connections select it too.
+	database string
+}
+
+func Dial(network, address string) (*Client, error) {
+	return dial(func(ctx context.Context) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	})
+}
+
+func DialTLS(network, address string, config *tls.Config) (*Client, error) {
+	return dial(func(ctx context.Context) (net.Conn, error) {
+		return (&tls.Dialer{Config: config}).DialContext(ctx, network, address)
+	})
+}
+
+func dial(dial func(ctx context.Context) (net.Conn, error)) (*Client, error) {
+	conn, err := dial(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		dial:   dial,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+		mutex:  &sync.Mutex{},
+	}, nil
+}
+
+func (client *Client) Get(key string) ([]byte, bool, error) {
+	status, value, err := client.call(opGet, []byte(key))
+	if err != nil {
+		return nil, false, err
+	}
+	return value, status == statusOK, nil
+}
+
+func (client *Client) Set(key string, value []byte) error {
+	_, _, err := client.call(opSet, []byte(key), value)
+	return err
+}
+
+func (client *Client) Del(key string) error {
+	_, _, err := client.call(opDel, []byte(key))
+	return err
+}
+
+// Watch calls fn for every change of a key matching the path.Match pattern until ctx is done
+// or the connection fails, a watcher falling too far behind is disconnected by the server.
+// It uses a connection of its own, so the client stays usable meanwhile.
+func (client *Client) Watch(ctx context.Context, pattern string, fn func(event Event)) error {
+	return client.watch(ctx, pattern, func() {}, fn)
+}
+
+// watch calls ready once the server acknowledged the watch, events before it are not delivered.
+func (client *Client) watch(ctx context.Context, pattern string, ready func(), fn func(event Event)) error {
+	conn, err := client.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client.mutex.Lock()
+	database := client.database
+	client.mutex.Unlock()
+
+	reader, writer := bufio.NewReader(conn), bufio.NewWriter(conn)
+	if database != "" {
+		if _, _, err := roundTrip(reader, writer, opUse, []byte(database)); err != nil {
+			return contextOr(ctx, err)
+		}
+	}
+	if _, _, err := roundTrip(reader, writer, opWatch, []byte(pattern)); err != nil {
+		return contextOr(ctx, err)
+	}
+	ready()
+	for {
+		head, fields, err := readFrame(reader)
+		if err != nil {
+			return contextOr(ctx, err)
+		}
+		if len(fields) != 2 {
+			return ErrMalformed
+		}
+		fn(Event{Kind: nanodb.EventKind(head), Key: string(fields[0]), Value: fields[1]})
+	}
+}
+
+// Use switches the client to a named database of a server with Databases set.
+func (client *Client) Use(database string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if _, _, err := roundTrip(client.reader, client.writer, opUse, []byte(database)); err != nil {
+		return err
+	}
+	client.database = database
+	return nil
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+func (client *Client) call(op byte, fields ...[]byte) (byte, []byte, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return roundTrip(client.reader, client.writer, op, fields...)
+}
+
+func roundTrip(reader io.Reader, writer *bufio.Writer, op byte, fields ...[]byte) (byte, []byte, error) {
+	if err := writeFrame(writer, op, fields...); err != nil {
+		return 0, nil, err
+	}
+	status, response, err := readFrame(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(response) != 1 {
+		return 0, nil, ErrMalformed
+	}
+	if status == statusError {
+		return 0, nil, &RemoteError{Message: string(response[0])}
+	}
+	return status, response[0], nil
+}
+
+func contextOr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func writeFrame(writer *bufio.Writer, head byte, fields ...[]byte) error {
+	size := 1
+	for _, field := range fields {
+		size += 4 + len(field)
+	}
+	if size > maxFrame {
+		return ErrFrameTooLarge
+	}
+
+	_ = binary.Write(writer, binary.BigEndian, uint32(size))
+	_ = writer.WriteByte(head)
+	for _, field := range fields {
+		_ = binary.Write(writer, binary.BigEndian, uint32(len(field)))
+		_, _ = writer.Write(field)
+	}
+	return writer.Flush()
+}
+
+func readFrame(reader io.Reader) (byte, [][]byte, error) {
+	size := uint32(0)
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return 0, nil, err
+	}
+	if size > maxFrame {
+		return 0, nil, ErrFrameTooLarge
+	}
+	if size == 0 {
+		return 0, nil, ErrMalformed
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return 0, nil, err
+	}
+
+	head, rest, fields := frame[0], frame[1:], [][]byte{}
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return 0, nil, ErrMalformed
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n) > uint64(len(rest)-4) {
+			return 0, nil, ErrMalformed
+		}
+		fields, rest = append(fields, rest[4:4+n]), rest[4+n:]
+	}
+	return head, fields, nil
+}
@@ -0,0 +1,378 @@
+package nanodbwire
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kittenbark/nanodb"
+)
+
+func serve(t *testing.T, network, address string) (*nanodb.DB[[]byte], *Client) {
+	t.Helper()
+
+	db := nanodb.New[[]byte]()
+	client, err := Dial(network, start(t, NewServer(db), network, address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return db, client
+}
+
+func start(t *testing.T, server *Server, network, address string) string {
+	t.Helper()
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- server.Serve(listener) }()
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Error(err)
+		}
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v", err)
+		}
+	})
+	return listener.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	for _, network := range []struct{ network, address string }{
+		{"tcp", "127.0.0.1:0"},
+		{"unix", filepath.Join(t.TempDir(), "nanodb.sock")},
+	} {
+		t.Run(network.network, func(t *testing.T) {
+			db, client := serve(t, network.network, network.address)
+
+			if err := client.Set("key", []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+			if value := db.Get("key"); string(value) != "value" {
+				t.Errorf("server has %q", value)
+			}
+			value, ok, err := client.Get("key")
+			if err != nil || !ok || string(value) != "value" {
+				t.Errorf("Get(key) = %q, %v, %v", value, ok, err)
+			}
+			if err := client.Del("key"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, err := client.Get("key"); ok || err != nil {
+				t.Errorf("deleted key: %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestClient_Refused(t *testing.T) {
+	db, client := serve(t, "tcp", "127.0.0.1:0")
+	db.Add("locked/key", []byte("kept")).Guard(func(op nanodb.Op, key string) error {
+		if op != nanodb.OpGet && strings.HasPrefix(key, "locked/") {
+			return errors.New("read-only prefix")
+		}
+		return nil
+	})
+
+	if err := client.Set("locked/new", []byte("value")); err == nil || !strings.Contains(err.Error(), "read-only prefix") {
+		t.Errorf("refused Set = %v", err)
+	}
+	if err := client.Del("locked/key"); err == nil {
+		t.Errorf("refused Del acknowledged")
+	}
+	if value := db.Get("locked/key"); string(value) != "kept" {
+		t.Errorf("refused Del removed the key")
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	_, client := serve(t, "tcp", "127.0.0.1:0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := make(chan Event, 10)
+	done := make(chan error)
+	go func() { done <- client.Watch(ctx, "user/*", func(event Event) { events <- event }) }()
+
+	// Writes race the watch registration, so keep writing until the first event arrives.
+	var first Event
+	for received := false; !received; {
+		if err := client.Set("user/alice", []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case first = <-events:
+			received = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if first.Key != "user/alice" || string(first.Value) != "1" {
+		t.Errorf("unexpected event %+v", first)
+	}
+	if err := client.Set("other", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Del("user/alice"); err != nil {
+		t.Fatal(err)
+	}
+	for event := range events {
+		if event.Kind == nanodb.EventDeleted {
+			if event.Key != "user/alice" {
+				t.Errorf("unexpected event %+v", event)
+			}
+			break
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch returned %v", err)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	db, client := serve(t, "tcp", "127.0.0.1:0")
+	mirror, err := NewMirror(client, "user/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mirror.Close()
+	eventually(t, func() bool {
+		mirror.mutex.Lock()
+		defer mirror.mutex.Unlock()
+		return mirror.watching
+	})
+
+	db.Add("user/alice", []byte("1"))
+	if value, ok, err := mirror.Get("user/alice"); err != nil || !ok || string(value) != "1" {
+		t.Fatalf("Get = %q, %v, %v", value, ok, err)
+	}
+	eventually(t, func() bool {
+		mirror.Get("user/alice")
+		_, cached := mirror.local.TryGet("user/alice")
+		return cached
+	})
+
+	db.Add("user/alice", []byte("2"))
+	eventually(t, func() bool {
+		value, _, _ := mirror.Get("user/alice")
+		return string(value) == "2"
+	})
+
+	db.Add("other", []byte("3"))
+	if value, _, _ := mirror.Get("other"); string(value) != "3" {
+		t.Errorf("uncached key: %q", value)
+	}
+	if _, cached := mirror.local.TryGet("other"); cached {
+		t.Errorf("key outside the pattern was cached")
+	}
+}
+
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+	}
+}
+
+func TestServer_TLS(t *testing.T) {
+	certificates := httptest.NewTLSServer(nil)
+	certificates.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(certificates.Certificate())
+
+	server := NewServer(nanodb.New[[]byte]()).TLS(certificates.TLS)
+	address := start(t, server, "tcp", "127.0.0.1:0")
+
+	client, err := DialTLS("tcp", address, &tls.Config{RootCAs: roots, ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Set("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, _, err := plain.Get("key"); err == nil {
+		t.Errorf("plain connection to a TLS server worked")
+	}
+}
+
+func TestServer_Limits(t *testing.T) {
+	server := NewServer(nanodb.New[[]byte]()).MaxConns(1).IdleTimeout(50*time.Millisecond).RateLimit(100, 1)
+	address := start(t, server, "tcp", "127.0.0.1:0")
+
+	first, err := Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	began := time.Now()
+	for range 10 {
+		if _, _, err := first.Get("key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(began); elapsed < 80*time.Millisecond {
+		t.Errorf("10 requests at 100/s took %v", elapsed)
+	}
+
+	second, err := Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if _, _, err := second.Get("key"); err == nil {
+		t.Errorf("connection over the limit was served")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, _, err := first.Get("key"); err == nil {
+		t.Errorf("idle connection was not closed")
+	}
+}
+
+func TestServer_LoggerAndAudit(t *testing.T) {
+	logged := &bytes.Buffer{}
+	logMutex := &sync.Mutex{}
+	logger := slog.New(slog.NewTextHandler(lockedWriter{logged, logMutex}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	records := make(chan AuditRecord, 10)
+	server := NewServer(nanodb.New[[]byte]()).Logger(logger).Audit(func(record AuditRecord) { records <- record })
+	client, err := Dial("tcp", start(t, server, "tcp", "127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Set("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	client.Get("missing")
+
+	record := <-records
+	if record.Method != "set" || record.Key != "key" || !strings.HasPrefix(record.Caller, "127.0.0.1:") {
+		t.Errorf("unexpected audit record %+v", record)
+	}
+	if len(records) != 0 {
+		t.Errorf("reads were audited")
+	}
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	for _, text := range []string{"method=set key=key", "outcome=ok", "method=get key=missing", "outcome=not_found", "latency="} {
+		if !strings.Contains(logged.String(), text) {
+			t.Errorf("log lacks %q:\n%s", text, logged)
+		}
+	}
+}
+
+type lockedWriter struct {
+	w     io.Writer
+	mutex *sync.Mutex
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.w.Write(p)
+}
+
+func TestServer_Databases(t *testing.T) {
+	opened := map[string]*nanodb.DB[[]byte]{}
+	server := NewServer(nanodb.New[[]byte]()).Databases(func(name string) (*nanodb.DB[[]byte], error) {
+		if name == "forbidden" {
+			return nil, errors.New("no such database")
+		}
+		opened[name] = nanodb.New[[]byte]()
+		return opened[name], nil
+	})
+	address := start(t, server, "tcp", "127.0.0.1:0")
+
+	clients := map[string]*Client{}
+	for _, name := range []string{"a", "b"} {
+		client, err := Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if err := client.Use(name); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Set("key", []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		clients[name] = client
+	}
+	for name, client := range clients {
+		if value, _, _ := client.Get("key"); string(value) != name {
+			t.Errorf("database %s reads %q", name, value)
+		}
+		if value := opened[name].Get("key"); string(value) != name {
+			t.Errorf("database %s holds %q", name, value)
+		}
+	}
+
+	var remote *RemoteError
+	if err := clients["a"].Use("forbidden"); !errors.As(err, &remote) {
+		t.Errorf("Use(forbidden) = %v", err)
+	}
+	if value, _, _ := clients["a"].Get("key"); string(value) != "a" {
+		t.Errorf("failed Use switched the database")
+	}
+
+	_, single := serve(t, "tcp", "127.0.0.1:0")
+	if err := single.Use("a"); err == nil {
+		t.Errorf("Use on a single database server worked")
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(nanodb.New[[]byte]())
+	done := make(chan error)
+	go func() { done <- server.Serve(listener) }()
+
+	client, err := Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Set("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve returned %v", err)
+	}
+	if _, _, err := client.Get("key"); err == nil {
+		t.Errorf("drained server kept serving")
+	}
+}